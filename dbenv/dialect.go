@@ -0,0 +1,127 @@
+package dbenv
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+)
+
+// Queryer is either database, connection or transaction.
+type Queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Dialect describes SQL flavour of the database engine. Flushing and dumping
+// of the data is built on top of it, so engine module needs to implement
+// only dialect to support fixtures and validators.
+type Dialect interface {
+	// QuoteIdent quotes single identifier, e.g. table or column name, so it
+	// can be used in query as is, even if it's a reserved word.
+	QuoteIdent(name string) string
+	// Placeholder returns query parameter placeholder with given index,
+	// starting from 1.
+	Placeholder(i int) string
+	// Tables returns schemas of all user tables in the database. Tables, as
+	// well as ForeignKey.RefTable, are always qualified with schema name, e.g.
+	// "public.users".
+	Tables(ctx context.Context, q Queryer) (map[string]TableSchema, error)
+	// CurrentSchema returns schema, which is used for unqualified names by
+	// default.
+	CurrentSchema(ctx context.Context, q Queryer) (string, error)
+	// Truncate returns statements, which remove all rows from tables in the
+	// same transaction. Tables are ordered, so referencing tables go before
+	// tables they reference.
+	Truncate(tables []string) []string
+	// BulkInsert returns statement, which inserts given number of rows with
+	// the same columns into the table.
+	BulkInsert(table string, columns []string, rows int) string
+}
+
+// IdentityResetter is implemented by dialects, which can reset generated
+// values of identity columns, so IDs of rows inserted after Flush don't
+// collide with fixtures and don't depend on previous runs.
+type IdentityResetter interface {
+	// ResetIdentity returns statements, which make next generated value of
+	// the identity column greater, than any existing value.
+	ResetIdentity(table, column string) []string
+}
+
+// ForeignKeysDisabler is implemented by dialects, which turn foreign key
+// checks off for the Flush transaction (e.g. in Truncate statements), so
+// tables can be filled in any order, even if their foreign keys form a cycle.
+type ForeignKeysDisabler interface {
+	// DisablesForeignKeys reports, that foreign keys are not checked, while
+	// Flush inserts rows.
+	DisablesForeignKeys() bool
+}
+
+// ReferencedTruncater is implemented by dialects, which Truncate statements
+// fail, when cleared tables are referenced by foreign keys of tables, which
+// are not cleared (e.g. postgres TRUNCATE).
+type ReferencedTruncater interface {
+	// TruncateReferenced works like Truncate, but it's used instead, when
+	// some of tables are referenced by tables, which keep their rows.
+	TruncateReferenced(tables []string) []string
+}
+
+// ValueDecoder is implemented by dialects, which driver returns raw values of
+// some columns, e.g. []byte for any column in text protocol of MySQL.
+type ValueDecoder interface {
+	// DecodeValue converts value, scanned from column of given database type,
+	// as it's reported by sql.ColumnType.DatabaseTypeName.
+	DecodeValue(dbType string, v driver.Value) (driver.Value, error)
+}
+
+// BulkLoader is implemented by dialects, which can load many rows much faster,
+// than multi-row INSERT does, e.g. with COPY protocol.
+type BulkLoader interface {
+	// BulkLoad inserts rows with the same columns into the table through the
+	// connection, which has transaction of Flush opened. If the loader can't
+	// encode some values, it returns false without changes in the table, so
+	// rows are inserted with BulkInsert statements.
+	BulkLoad(ctx context.Context, conn *sql.Conn, table string, schema TableSchema, columns []string, rows [][]any) (bool, error)
+}
+
+// QuoteName quotes possibly schema-qualified name, e.g. "billing.invoices".
+func QuoteName(d Dialect, name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = d.QuoteIdent(part)
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// BulkInsert builds multi-row INSERT statement, which is supported by most
+// engines, so dialects can use it as BulkInsert implementation.
+func BulkInsert(d Dialect, table string, columns []string, rows int) string {
+	var b strings.Builder
+	b.WriteString("INSERT INTO ")
+	b.WriteString(QuoteName(d, table))
+	b.WriteString(" (")
+	for i, column := range columns {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(d.QuoteIdent(column))
+	}
+	b.WriteString(") VALUES ")
+
+	for row := 0; row < rows; row++ {
+		if row > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for i := range columns {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(d.Placeholder(row*len(columns) + i + 1))
+		}
+		b.WriteByte(')')
+	}
+
+	return b.String()
+}
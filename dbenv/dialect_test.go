@@ -0,0 +1,23 @@
+package dbenv_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/internal/fakedb"
+)
+
+func TestQuoteName(t *testing.T) {
+	require.Equal(t, `"order"`, dbenv.QuoteName(fakedb.Dialect{}, "order"))
+	require.Equal(t, `"billing"."Invoices"`, dbenv.QuoteName(fakedb.Dialect{}, "billing.Invoices"))
+	require.Equal(t, `"a""b"`, dbenv.QuoteName(fakedb.Dialect{}, `a"b`))
+}
+
+func TestBulkInsert(t *testing.T) {
+	require.Equal(t,
+		`INSERT INTO "billing"."order" ("id", "user") VALUES ($1, $2), ($3, $4)`,
+		fakedb.Dialect{}.BulkInsert("billing.order", []string{"id", "user"}, 2),
+	)
+}
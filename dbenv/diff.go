@@ -0,0 +1,177 @@
+package dbenv
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/quenbyako/ext/slices"
+)
+
+// Baseline is saved state of the database, which is compared with current
+// state to find out, what exactly test has changed.
+type Baseline struct {
+	tables map[string]TableData
+}
+
+// NewBaseline dumps all tables of the container. It's better to make baseline
+// right after Flush, so diff contains only changes of the test.
+func NewBaseline(ctx context.Context, c Container) (*Baseline, error) {
+	tables, err := c.Dump(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Baseline{tables: tables}, nil
+}
+
+// Tables returns dumped tables of the baseline.
+func (b *Baseline) Tables() map[string]TableData { return b.tables }
+
+// Diff returns changes of every table, which was modified since baseline.
+func (b *Baseline) Diff(ctx context.Context, c Container) (map[string]TableDiff, error) {
+	tables, err := c.Dump(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffTables(b.tables, tables), nil
+}
+
+// TableDiff is a set of changes of single table.
+type TableDiff struct {
+	Schema   TableSchema
+	Inserted []TableRow
+	Updated  []RowChange
+	Deleted  []TableRow
+}
+
+// Empty returns true, if table is not changed.
+func (d TableDiff) Empty() bool {
+	return len(d.Inserted) == 0 && len(d.Updated) == 0 && len(d.Deleted) == 0
+}
+
+// RowChange is a row, which was updated in place.
+type RowChange struct {
+	Before, After TableRow
+}
+
+// DiffTables compares two dumps of the database. Rows are matched by primary
+// key, so changed rows are reported as updated. Rows of tables without
+// primary key are matched by all columns, so changed row is reported as
+// deleted and inserted one. Only changed tables are returned.
+func DiffTables(before, after map[string]TableData) map[string]TableDiff {
+	res := make(map[string]TableDiff)
+	for name, table := range after {
+		if d := diffTable(before[name], table); !d.Empty() {
+			res[name] = d
+		}
+	}
+	for name, table := range before {
+		if _, ok := after[name]; ok {
+			continue
+		}
+		if d := diffTable(table, TableData{Schema: table.Schema}); !d.Empty() {
+			res[name] = d
+		}
+	}
+
+	return res
+}
+
+func diffTable(before, after TableData) TableDiff {
+	res := TableDiff{Schema: after.Schema}
+
+	keys := after.Schema.PrimaryKeys
+	if len(keys) == 0 || !slices.Equal(keys, before.Schema.PrimaryKeys) {
+		keys = nil // rows are matched by all columns
+	}
+
+	// rows without primary key may be duplicated, so every key keeps all
+	// matching rows.
+	old := make(map[string][]TableRow, len(before.Rows))
+	for _, row := range before.Rows {
+		k := rowKey(keys, row)
+		old[k] = append(old[k], row)
+	}
+
+	for _, row := range after.Rows {
+		k := rowKey(keys, row)
+		matched := old[k]
+		if len(matched) == 0 {
+			res.Inserted = append(res.Inserted, row)
+			continue
+		}
+		old[k] = matched[1:]
+
+		if !RowsEqual(matched[0], row) {
+			res.Updated = append(res.Updated, RowChange{Before: matched[0], After: row})
+		}
+	}
+
+	// deleted rows keep order of the dump.
+	for _, row := range before.Rows {
+		k := rowKey(keys, row)
+		if matched := old[k]; len(matched) > 0 {
+			res.Deleted = append(res.Deleted, matched[0])
+			old[k] = matched[1:]
+		}
+	}
+
+	return res
+}
+
+// rowKey builds comparable identifier of the row from given columns, or from
+// all columns, if keys are empty.
+func rowKey(keys []string, row TableRow) string {
+	if len(keys) == 0 {
+		for column := range row {
+			keys = append(keys, column)
+		}
+		sort.Strings(keys)
+	}
+
+	var b strings.Builder
+	for _, key := range keys {
+		v := row[key]
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC().Format(time.RFC3339Nano)
+		}
+		fmt.Fprintf(&b, "%q:%T:%v\x00", key, v, v)
+	}
+
+	return b.String()
+}
+
+// RowsEqual returns true, if rows have the same columns with equal values.
+func RowsEqual(a, b TableRow) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for column, value := range a {
+		other, ok := b[column]
+		if !ok || !valuesEqual(value, other) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func valuesEqual(a, b driver.Value) bool {
+	switch a := a.(type) {
+	case []byte:
+		b, ok := b.([]byte)
+		return ok && bytes.Equal(a, b)
+	case time.Time:
+		b, ok := b.(time.Time)
+		return ok && a.Equal(b)
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
@@ -0,0 +1,47 @@
+package dbenv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffTables(t *testing.T) {
+	users := TableSchema{PrimaryKeys: []string{"id"}}
+	before := map[string]TableData{
+		"users": {Schema: users, Rows: []TableRow{
+			{"id": int64(1), "name": "John"},
+			{"id": int64(2), "name": "Jane"},
+			{"id": int64(3), "name": "Bob"},
+		}},
+		"log":     {Rows: []TableRow{{"msg": "a"}, {"msg": "a"}, {"msg": "b"}}},
+		"kept":    {Rows: []TableRow{{"data": []byte("x")}}},
+		"removed": {Rows: []TableRow{{"msg": "a"}}},
+	}
+	after := map[string]TableData{
+		"users": {Schema: users, Rows: []TableRow{
+			{"id": int64(1), "name": "John"},
+			{"id": int64(2), "name": "Alice"},
+			{"id": int64(4), "name": "Eve"},
+		}},
+		"log":  {Rows: []TableRow{{"msg": "a"}, {"msg": "c"}, {"msg": "b"}}},
+		"kept": {Rows: []TableRow{{"data": []byte("x")}}},
+	}
+
+	require.Equal(t, map[string]TableDiff{
+		"users": {
+			Schema:   users,
+			Inserted: []TableRow{{"id": int64(4), "name": "Eve"}},
+			Updated:  []RowChange{{Before: TableRow{"id": int64(2), "name": "Jane"}, After: TableRow{"id": int64(2), "name": "Alice"}}},
+			Deleted:  []TableRow{{"id": int64(3), "name": "Bob"}},
+		},
+		// rows without primary key can't be updated, only replaced
+		"log": {
+			Inserted: []TableRow{{"msg": "c"}},
+			Deleted:  []TableRow{{"msg": "a"}},
+		},
+		"removed": {
+			Deleted: []TableRow{{"msg": "a"}},
+		},
+	}, DiffTables(before, after))
+}
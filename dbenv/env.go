@@ -3,6 +3,7 @@ package dbenv
 import (
 	"context"
 	"database/sql/driver"
+	"time"
 )
 
 type TableData struct {
@@ -13,20 +14,48 @@ type TableData struct {
 type TableRow map[string]driver.Value
 
 type Container interface {
-	// ConnString returns the connection string for the database container, using the default 5432 port, and
-	// obtaining the host and exposed port from the container. It also accepts a variadic list of extra arguments
-	// which will be appended to the connection string. The format of the extra arguments is the same as the
-	// connection string format, e.g. "connect_timeout=10" or "application_name=myapp"
+	// ConnString returns the connection string of the database in the format
+	// of its driver, e.g. postgres URL or SQLite DSN, so the code under test
+	// can connect to the same database.
 	ConnString(context.Context) (string, error)
 	Close() error
 
 	Dump(context.Context) (map[string]TableData, error)
 	Flush(context.Context, map[string][]TableRow) error
+	// Query runs arbitrary query and returns all rows of its result.
+	Query(ctx context.Context, query string, args ...any) ([]TableRow, error)
+}
+
+// Snapshotter is implemented by containers, which can save whole database
+// state and bring it back much faster, than Flush does.
+type Snapshotter interface {
+	// Snapshot saves current state of the database with given name. Existing
+	// snapshot with the same name is replaced.
+	Snapshot(ctx context.Context, name string) error
+	// Restore replaces the database with saved snapshot.
+	Restore(ctx context.Context, name string) error
+	// Clone creates new separate database from snapshot. Returned container
+	// drops the database on Close.
+	Clone(ctx context.Context, snapshot string) (Container, error)
+}
+
+// TableStats describes how fixtures were loaded into single table by Flush.
+type TableStats struct {
+	Table string
+	Rows  int
+	// Copied is the number of rows, which were loaded with BulkLoader instead
+	// of INSERT statements.
+	Copied   int
+	Duration time.Duration
 }
 
 type TableSchema struct {
 	PrimaryKeys []string
 	Types       []ColumnType
+	ForeignKeys []ForeignKey
+	// Uniques are unique constraints and unique indexes, except primary key.
+	Uniques []Unique
+	Checks  []Check
 }
 
 func (s TableSchema) TypeMap() map[string]string {
@@ -38,4 +67,53 @@ func (s TableSchema) TypeMap() map[string]string {
 	return res
 }
 
-type ColumnType struct{ Name, Typ string }
+// Column returns description of the column by its name.
+func (s TableSchema) Column(name string) (ColumnType, bool) {
+	for _, t := range s.Types {
+		if t.Name == name {
+			return t, true
+		}
+	}
+
+	return ColumnType{}, false
+}
+
+// ColumnType describes single column of the table. Only Name and Typ are
+// required, other fields are filled, if database engine supports them.
+type ColumnType struct {
+	Name, Typ string
+	// Nullable is true, if column accepts NULL values.
+	Nullable bool
+	// Default is SQL expression of default value, e.g. "now()", or empty
+	// string, if column has no default.
+	Default string
+	// Identity is true for identity, serial and auto increment columns, which
+	// are generated by database, when value is omitted.
+	Identity bool
+}
+
+// ForeignKey describes a single foreign key constraint of the table. Columns
+// and RefColumns are matched by position.
+type ForeignKey struct {
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	// OnDelete and OnUpdate are referential actions, e.g. "CASCADE",
+	// "SET NULL" or "NO ACTION".
+	OnDelete string
+	OnUpdate string
+}
+
+// Unique describes unique constraint of the table.
+type Unique struct {
+	Name    string
+	Columns []string
+}
+
+// Check describes check constraint of the table.
+type Check struct {
+	Name string
+	// Expr is the condition of the constraint, e.g. "(price > 0)".
+	Expr string
+}
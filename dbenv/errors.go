@@ -0,0 +1,36 @@
+package dbenv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrConnection is returned, when container can't be reached: connection
+	// string can't be built, or database doesn't accept connections.
+	ErrConnection = errors.New("database connection failed")
+	// ErrIntrospection is returned, when database schema can't be fetched.
+	ErrIntrospection = errors.New("schema introspection failed")
+	// ErrSnapshotNotFound is returned, when requested snapshot doesn't exist.
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+)
+
+// QueryError is returned, when database fails to execute the statement.
+type QueryError struct {
+	Query string
+	Err   error
+}
+
+func (e *QueryError) Error() string { return fmt.Sprintf("query %q: %v", e.Query, e.Err) }
+func (e *QueryError) Unwrap() error { return e.Err }
+
+// CycleError is returned, when tables reference each other through foreign
+// keys, so there is no order to insert data in.
+type CycleError struct {
+	Tables []string // cycle path, first and last tables are the same
+}
+
+func (e *CycleError) Error() string {
+	return "foreign keys cycle found: " + strings.Join(e.Tables, " -> ")
+}
@@ -0,0 +1,40 @@
+package dbenv
+
+// FlushMode selects tables, which are cleared by Flush before fixtures are
+// inserted. Engine modules set it with WithFlushMode option, FlushAll is used
+// by default.
+//
+// Tables, which are not cleared, keep their rows, so their foreign keys may
+// reference removed rows: depending on the engine and foreign key rules such
+// rows are removed too (ON DELETE CASCADE), Flush fails, or foreign keys are
+// not checked at all. Use FlushCascade to clear referencing tables as well.
+type FlushMode int
+
+const (
+	// FlushAll clears every visible table, so database contains only
+	// fixtures after Flush.
+	FlushAll FlushMode = iota
+	// FlushListed clears only tables, which are present in fixtures, and
+	// keeps rows of other tables, e.g. reference data of init scripts.
+	FlushListed
+	// FlushCascade clears tables, which are present in fixtures, and all
+	// tables, which reference them directly or through other tables, so
+	// foreign keys don't prevent clearing.
+	FlushCascade
+)
+
+// FlushPolicy defines, which tables are cleared by Flush. Zero value clears
+// all visible tables. Engine modules set it with WithFlushMode and
+// WithProtected options.
+type FlushPolicy struct {
+	Mode FlushMode
+	// Protected are path.Match patterns of qualified table names, the same
+	// as Schemas.Include, which are never cleared, e.g. lookup or enum tables.
+	// Fixtures of protected tables are rejected.
+	Protected []string
+}
+
+// Protects reports whether table of the schema must not be cleared.
+func (p FlushPolicy) Protects(schema, table string) bool {
+	return matchAny(p.Protected, schema+"."+table)
+}
@@ -0,0 +1,49 @@
+package util_test
+
+import (
+	"context"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/dbenv/internal/util"
+	"github.com/quenbyako/sqltest/dbenv/sqlite"
+)
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Unknown driver", func(t *testing.T) {
+		_, err := util.ConnectSQLContext(ctx, "unknown", "")
+		require.ErrorIs(t, err, dbenv.ErrConnection)
+	})
+
+	t.Run("Unreachable database", func(t *testing.T) {
+		_, err := util.ConnectSQLContext(ctx, "sqlite3", "file:"+t.TempDir()+"/missing/db.sqlite?mode=ro")
+		require.ErrorIs(t, err, dbenv.ErrConnection)
+	})
+
+	t.Run("Broken introspection", func(t *testing.T) {
+		db, err := util.ConnectSQLContext(ctx, "sqlite3", "file::memory:")
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		_, _, err = util.Tables(ctx, db, sqlite.Dialect(), dbenv.Schemas{})
+		require.ErrorIs(t, err, dbenv.ErrIntrospection)
+		var queryErr *dbenv.QueryError
+		require.ErrorAs(t, err, &queryErr)
+		require.NotEmpty(t, queryErr.Query)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		_, err := util.InsertOrder(map[string]dbenv.TableSchema{
+			"a": {ForeignKeys: []dbenv.ForeignKey{{RefTable: "b"}}},
+			"b": {ForeignKeys: []dbenv.ForeignKey{{RefTable: "a"}}},
+		})
+		var cycle *dbenv.CycleError
+		require.ErrorAs(t, err, &cycle)
+		require.Equal(t, []string{"a", "b", "a"}, cycle.Tables)
+	})
+}
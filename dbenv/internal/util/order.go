@@ -0,0 +1,73 @@
+package util
+
+import (
+	"sort"
+
+	"github.com/quenbyako/ext/slices"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+// InsertOrder returns table names sorted in the way, that every table goes
+// after all tables it references through foreign keys. So data can be inserted
+// in returned order, and deleted in reversed one. Self references are ignored,
+// references to unknown tables as well.
+func InsertOrder(tables map[string]dbenv.TableSchema) ([]string, error) {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	deps := make(map[string][]string, len(tables))
+	for _, name := range names {
+		for _, fk := range tables[name].ForeignKeys {
+			if _, ok := tables[fk.RefTable]; !ok || fk.RefTable == name {
+				continue
+			}
+			deps[name] = slices.GentlyAppend(deps[name], fk.RefTable)
+		}
+		sort.Strings(deps[name])
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(tables))
+	res := make([]string, 0, len(tables))
+
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return &dbenv.CycleError{Tables: slices.Concat(path[slices.Index(path, name):], []string{name})}
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range deps[name] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		res = append(res, name)
+
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
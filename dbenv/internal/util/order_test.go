@@ -0,0 +1,60 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+func TestInsertOrder(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		tables  map[string]dbenv.TableSchema
+		want    []string
+		wantErr string
+	}{{
+		name: "No references",
+		tables: map[string]dbenv.TableSchema{
+			"b": {},
+			"a": {},
+		},
+		want: []string{"a", "b"},
+	}, {
+		name: "Referenced table goes first",
+		tables: map[string]dbenv.TableSchema{
+			"names":  {ForeignKeys: []dbenv.ForeignKey{{Columns: []string{"group_id"}, RefTable: "groups", RefColumns: []string{"id"}}}},
+			"groups": {},
+		},
+		want: []string{"groups", "names"},
+	}, {
+		name: "Self reference and unknown tables are ignored",
+		tables: map[string]dbenv.TableSchema{
+			"nodes": {ForeignKeys: []dbenv.ForeignKey{
+				{Columns: []string{"parent_id"}, RefTable: "nodes", RefColumns: []string{"id"}},
+				{Columns: []string{"owner_id"}, RefTable: "users", RefColumns: []string{"id"}},
+			}},
+		},
+		want: []string{"nodes"},
+	}, {
+		name: "Cycle",
+		tables: map[string]dbenv.TableSchema{
+			"a": {ForeignKeys: []dbenv.ForeignKey{{RefTable: "b"}}},
+			"b": {ForeignKeys: []dbenv.ForeignKey{{RefTable: "c"}}},
+			"c": {ForeignKeys: []dbenv.ForeignKey{{RefTable: "a"}}},
+		},
+		wantErr: "foreign keys cycle found: a -> b -> c -> a",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InsertOrder(tt.tables)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
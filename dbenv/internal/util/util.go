@@ -4,12 +4,21 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
+	"github.com/quenbyako/ext/maps"
 	"github.com/quenbyako/ext/slices"
 	"github.com/testcontainers/testcontainers-go"
 
@@ -18,13 +27,15 @@ import (
 
 func GetContainerEnv(ctx context.Context, c testcontainers.Container) (map[string]string, error) {
 	exit, outReader, err := c.Exec(ctx, []string{"/usr/bin/env"})
-	if err != nil || exit != 0 {
-		panic(fmt.Sprintf("%v %v", exit, err))
+	if err != nil {
+		return nil, fmt.Errorf("can't read container environment: %w", err)
 	}
 
-	stdout, _, err := DemultiplexeDockerOut(outReader)
+	stdout, stderr, err := DemultiplexeDockerOut(outReader)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("can't read container environment: %w", err)
+	} else if exit != 0 {
+		return nil, fmt.Errorf("can't read container environment: exit code %v: %s", exit, bytes.TrimSpace(stderr))
 	}
 
 	env := map[string]string{}
@@ -33,8 +44,9 @@ func GetContainerEnv(ctx context.Context, c testcontainers.Container) (map[strin
 			continue
 		}
 
-		i := strings.IndexRune(line, '=')
-		env[line[:i]] = line[i+1:]
+		if k, v, ok := strings.Cut(line, "="); ok {
+			env[k] = v
+		}
 	}
 	return env, nil
 }
@@ -64,37 +76,546 @@ func ContainerHostPort(ctx context.Context, c testcontainers.Container, p nat.Po
 	return host, containerPort.Int(), nil
 }
 
+// StopContainer stops container within ctx. Container gets timeout seconds to
+// stop gracefully, but not more than left until the deadline of ctx.
+func StopContainer(ctx context.Context, c testcontainers.Container, timeout time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(deadline))
+	}
+
+	return c.Stop(ctx, &timeout)
+}
+
+// LogConsumer writes container logs to W. testcontainers ignores failures of
+// consumers, so first write error is kept and returned by Err.
+type LogConsumer struct {
+	W io.Writer
+
+	mu  sync.Mutex
+	err error
+}
+
+var _ testcontainers.LogConsumer = (*LogConsumer)(nil)
+
+func (l *LogConsumer) Accept(log testcontainers.Log) {
+	if _, err := l.W.Write(log.Content); err != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.err == nil {
+			l.err = fmt.Errorf("writing container logs: %w", err)
+		}
+	}
+}
+
+// Err returns first error of writing logs.
+func (l *LogConsumer) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.err
+}
+
 func ConnectSQLContext(ctx context.Context, driverName, dataSourceName string) (*sql.DB, error) {
 	db, err := sql.Open(driverName, dataSourceName)
 	if err != nil {
-		return db, err
+		return nil, fmt.Errorf("%w: %w", dbenv.ErrConnection, err)
+	}
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%w: %w", dbenv.ErrConnection, err)
+	}
+
+	return db, nil
+}
+
+// Tables returns tables, which are visible through schemas, named as
+// schemas define. Returned schemas always have default schema set.
+func Tables(ctx context.Context, q dbenv.Queryer, d dbenv.Dialect, schemas dbenv.Schemas) (map[string]dbenv.TableSchema, dbenv.Schemas, error) {
+	visible, _, schemas, err := allTables(ctx, q, d, schemas)
+	return visible, schemas, err
+}
+
+// allTables works like Tables, but it also returns all tables of the
+// database, including invisible ones.
+func allTables(ctx context.Context, q dbenv.Queryer, d dbenv.Dialect, schemas dbenv.Schemas) (visible, all map[string]dbenv.TableSchema, _ dbenv.Schemas, _ error) {
+	if schemas.Default == "" {
+		current, err := d.CurrentSchema(ctx, q)
+		if err != nil {
+			return nil, nil, schemas, err
+		}
+		schemas.Default = current
+	}
+
+	tables, err := d.Tables(ctx, q)
+	if err != nil {
+		return nil, nil, schemas, err
+	}
+
+	rename := func(qualified string) string { return schemas.Name(schemas.Qualify(qualified)) }
+
+	visible = make(map[string]dbenv.TableSchema, len(tables))
+	all = make(map[string]dbenv.TableSchema, len(tables))
+	for qualified, table := range tables {
+		table.ForeignKeys = slices.Clone(table.ForeignKeys)
+		for i, fk := range table.ForeignKeys {
+			table.ForeignKeys[i].RefTable = rename(fk.RefTable)
+		}
+
+		all[rename(qualified)] = table
+		if schemas.Visible(schemas.Qualify(qualified)) {
+			visible[rename(qualified)] = table
+		}
 	}
-	err = db.PingContext(ctx)
-	return db, err
+
+	return visible, all, schemas, nil
+}
+
+// qualified returns name of the table with schema, so it doesn't depend on
+// search path of the connection.
+func qualified(schemas dbenv.Schemas, name string) string {
+	schema, table := schemas.Qualify(name)
+	return schema + "." + table
 }
 
-func DumpTable(ctx context.Context, tx Tx, tableName string, schema dbenv.TableSchema) (data []dbenv.TableRow, err error) {
+// Flush replaces data of tables, which are selected by policy, with given rows
+// in single transaction. Tables may be named with default schema, e.g.
+// "public.users", as well as without it. Returned stats are ordered the same
+// way, as tables were filled.
+func Flush(ctx context.Context, db *sql.DB, d dbenv.Dialect, schemas dbenv.Schemas, policy dbenv.FlushPolicy, data map[string][]dbenv.TableRow) ([]dbenv.TableStats, error) {
+	tables, all, schemas, err := allTables(ctx, db, d, schemas)
+	if err != nil {
+		return nil, err
+	}
+
+	named := make(map[string][]dbenv.TableRow, len(data))
+	for name, rows := range data {
+		table := schemas.Name(schemas.Qualify(name))
+		if _, ok := tables[table]; !ok {
+			return nil, fmt.Errorf("table %#v: not exists in database", name)
+		} else if _, ok := named[table]; ok {
+			return nil, fmt.Errorf("table %#v: defined twice", table)
+		} else if policy.Protects(schemas.Qualify(table)) {
+			return nil, fmt.Errorf("table %#v: protected from flush", table)
+		}
+		named[table] = rows
+	}
+
+	// only cleared tables are ordered, so cycles between other tables don't
+	// matter.
+	cleared := make(map[string]dbenv.TableSchema)
+	for name := range clearedTables(tables, schemas, policy, named) {
+		cleared[name] = tables[name]
+	}
+
+	order, err := InsertOrder(cleared)
+	var cycle *dbenv.CycleError
+	if errors.As(err, &cycle) && disablesForeignKeys(d) {
+		// order doesn't matter, when foreign keys are not checked.
+		order = slices.Sort(maps.Keys(cleared))
+	} else if err != nil {
+		return nil, err
+	}
+
+	// bulk loader works with driver connection directly, so transaction must
+	// be opened on the same one.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", dbenv.ErrConnection, err)
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", dbenv.ErrConnection, err)
+	}
+	defer tx.Rollback()
+
+	// удаляем в обратном порядке, чтобы сначала чистились таблицы, которые
+	// ссылаются на другие
+	reversed := slices.Reverse(slices.Remap(order, func(name string) string { return qualified(schemas, name) }))
+	truncate := d.Truncate
+	if r, ok := d.(dbenv.ReferencedTruncater); ok && len(referencedTables(all, cleared)) > 0 {
+		truncate = r.TruncateReferenced
+	}
+	for _, query := range truncate(reversed) {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return nil, &dbenv.QueryError{Query: query, Err: err}
+		}
+	}
+
+	var stats []dbenv.TableStats
+	for _, name := range order {
+		if values, ok := named[name]; ok {
+			start := time.Now()
+			copied, err := loadData(ctx, conn, tx, d, qualified(schemas, name), tables[name], values)
+			if err != nil {
+				return nil, fmt.Errorf("table %#v: %w", name, err)
+			}
+
+			stats = append(stats, dbenv.TableStats{
+				Table:    name,
+				Rows:     len(values),
+				Copied:   copied,
+				Duration: time.Since(start),
+			})
+		}
+	}
+
+	if r, ok := d.(dbenv.IdentityResetter); ok {
+		for _, name := range order {
+			if err := resetIdentity(ctx, tx, r, qualified(schemas, name), tables[name]); err != nil {
+				return nil, fmt.Errorf("table %#v: %w", name, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, &dbenv.QueryError{Query: "COMMIT", Err: err}
+	}
+
+	return stats, nil
+}
+
+func disablesForeignKeys(d dbenv.Dialect) bool {
+	f, ok := d.(dbenv.ForeignKeysDisabler)
+	return ok && f.DisablesForeignKeys()
+}
+
+// loadData inserts rows with dialect's bulk loader, if it's supported, and
+// with INSERT statements otherwise. It returns number of rows, which were
+// loaded by bulk loader.
+func loadData(ctx context.Context, conn *sql.Conn, tx Tx, d dbenv.Dialect, tableName string, schema dbenv.TableSchema, data []dbenv.TableRow) (int, error) {
+	groups, err := groupRows(schema, data)
+	if err != nil {
+		return 0, err
+	}
+
+	loader, _ := d.(dbenv.BulkLoader)
+
+	var copied int
+	for _, g := range groups {
+		if loader != nil {
+			ok, err := loader.BulkLoad(ctx, conn, tableName, schema, g.columns, g.rows)
+			if err != nil {
+				return copied, g.wrap(0, len(g.rows), err)
+			} else if ok {
+				copied += len(g.rows)
+				continue
+			}
+		}
+
+		if err := insertGroup(ctx, tx, d, tableName, g); err != nil {
+			return copied, err
+		}
+	}
+
+	return copied, nil
+}
+
+// clearedTables returns names of tables, which are cleared by Flush of given
+// fixtures.
+func clearedTables(tables map[string]dbenv.TableSchema, schemas dbenv.Schemas, policy dbenv.FlushPolicy, data map[string][]dbenv.TableRow) map[string]bool {
+	res := make(map[string]bool, len(tables))
+	switch policy.Mode {
+	case dbenv.FlushListed, dbenv.FlushCascade:
+		for name := range data {
+			res[name] = true
+		}
+	default:
+		for name := range tables {
+			res[name] = true
+		}
+	}
+
+	if policy.Mode == dbenv.FlushCascade {
+		dependents := make(map[string][]string, len(tables))
+		for name, schema := range tables {
+			for _, fk := range schema.ForeignKeys {
+				dependents[fk.RefTable] = append(dependents[fk.RefTable], name)
+			}
+		}
+
+		queue := maps.Keys(res)
+		for len(queue) > 0 {
+			name := queue[0]
+			queue = queue[1:]
+			for _, dependent := range dependents[name] {
+				if !res[dependent] && !policy.Protects(schemas.Qualify(dependent)) {
+					res[dependent] = true
+					queue = append(queue, dependent)
+				}
+			}
+		}
+	}
+
+	for name := range res {
+		if policy.Protects(schemas.Qualify(name)) {
+			delete(res, name)
+		}
+	}
+
+	return res
+}
+
+// referencedTables returns names of cleared tables, which are referenced by
+// foreign keys of tables, which are not cleared, including invisible ones.
+func referencedTables(tables, cleared map[string]dbenv.TableSchema) []string {
+	referenced := make(map[string]bool)
+	for name, schema := range tables {
+		if _, ok := cleared[name]; ok {
+			continue
+		}
+		for _, fk := range schema.ForeignKeys {
+			if _, ok := cleared[fk.RefTable]; ok {
+				referenced[fk.RefTable] = true
+			}
+		}
+	}
+
+	return slices.Sort(maps.Keys(referenced))
+}
+
+func resetIdentity(ctx context.Context, tx Tx, r dbenv.IdentityResetter, tableName string, schema dbenv.TableSchema) error {
+	for _, column := range schema.Types {
+		if !column.Identity {
+			continue
+		}
+
+		for _, query := range r.ResetIdentity(tableName, column.Name) {
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("column %q: %w", column.Name, &dbenv.QueryError{Query: query, Err: err})
+			}
+		}
+	}
+
+	return nil
+}
+
+// Dump reads all rows of all visible tables in the database.
+func Dump(ctx context.Context, tx Tx, d dbenv.Dialect, schemas dbenv.Schemas) (map[string]dbenv.TableData, error) {
+	tables, schemas, err := Tables(ctx, tx, d, schemas)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]dbenv.TableData)
+	for name, schema := range tables {
+		data, err := DumpTable(ctx, tx, d, qualified(schemas, name), schema)
+		if err != nil {
+			return nil, fmt.Errorf("table %#v: %w", name, err)
+		}
+
+		res[name] = dbenv.TableData{
+			Schema: schema,
+			Rows:   data,
+		}
+	}
+
+	return res, nil
+}
+
+func DumpTable(ctx context.Context, tx Tx, d dbenv.Dialect, tableName string, schema dbenv.TableSchema) (data []dbenv.TableRow, err error) {
 	// мы не можем здесь без шаманства с запросом, так как prepared запрос не
 	// поддерживает динамическое изменение названия таблицы. Это связано с тем,
 	// что prepare готовит план запроса под конкретную схему данных, поэтому
 	// любая динамическая схема невозможна впринципе.
-	rows, err := tx.QueryContext(ctx, "SELECT * FROM "+tableName+"ORDER BY "+strings.Join(slices.Remap(schema.PrimaryKeys, func(s string) string { return s + " ASC" }), ", "))
+	query := "SELECT * FROM " + dbenv.QuoteName(d, tableName)
+	if len(schema.PrimaryKeys) > 0 {
+		query += " ORDER BY " + strings.Join(slices.Remap(schema.PrimaryKeys, func(s string) string { return d.QuoteIdent(s) + " ASC" }), ", ")
+	}
+
+	return QueryRows(ctx, tx, d, query)
+}
+
+// QueryRows returns all rows of the query. Values are decoded by dialect, if
+// it implements dbenv.ValueDecoder.
+func QueryRows(ctx context.Context, tx Tx, d dbenv.Dialect, query string, args ...any) (data []dbenv.TableRow, err error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, &dbenv.QueryError{Query: query, Err: err}
+	}
+	defer rows.Close()
+
+	decoder, _ := d.(dbenv.ValueDecoder)
+	var types []*sql.ColumnType
+	if decoder != nil {
+		if types, err = rows.ColumnTypes(); err != nil {
+			return nil, &dbenv.QueryError{Query: query, Err: err}
+		}
 	}
 
 	for rows.Next() {
 		m, err := MapScan(rows)
 		if err != nil {
-			return nil, err
+			return nil, &dbenv.QueryError{Query: query, Err: err}
+		}
+
+		for _, typ := range types {
+			if m[typ.Name()], err = decoder.DecodeValue(typ.DatabaseTypeName(), m[typ.Name()]); err != nil {
+				return nil, &dbenv.QueryError{Query: query, Err: fmt.Errorf("column %#v: %w", typ.Name(), err)}
+			}
 		}
 
 		data = append(data, m)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, &dbenv.QueryError{Query: query, Err: err}
+	}
 
 	return data, nil
 }
 
-func InsertData(ctx context.Context, tx Tx, tableName string, schema dbenv.TableSchema, data []dbenv.TableRow) error {
-	panic("unimplemented")
+// maxBulkArgs is the lowest limit of query parameters among supported
+// engines (old SQLite versions).
+const maxBulkArgs = 999
+
+// rowGroup is consecutive rows of the table with the same set of columns.
+type rowGroup struct {
+	first   int // index of the first row in table data
+	columns []string
+	rows    [][]any
+}
+
+// wrap adds indexes of rows [start, end) of the group to the error.
+func (g rowGroup) wrap(start, end int, err error) error {
+	if end-start == 1 {
+		return fmt.Errorf("row %v: %w", g.first+start, err)
+	}
+	return fmt.Errorf("rows %v-%v: %w", g.first+start, g.first+end-1, err)
+}
+
+// groupRows coerces rows and splits them into groups with the same columns.
+func groupRows(schema dbenv.TableSchema, data []dbenv.TableRow) ([]rowGroup, error) {
+	var res []rowGroup
+	for i, raw := range data {
+		row, err := CoerceRow(schema, raw)
+		if err != nil {
+			return nil, fmt.Errorf("row %v: %w", i, err)
+		}
+
+		if len(res) == 0 || !sameColumns(row, res[len(res)-1].columns) {
+			columns := make([]string, 0, len(row))
+			for column := range row {
+				columns = append(columns, column)
+			}
+			sort.Strings(columns)
+
+			res = append(res, rowGroup{first: i, columns: columns})
+		}
+
+		g := &res[len(res)-1]
+		g.rows = append(g.rows, slices.Remap(g.columns, func(column string) any { return row[column] }))
+	}
+
+	return res, nil
+}
+
+// insertGroup inserts rows of the group with as few statements, as query
+// parameters limit allows.
+func insertGroup(ctx context.Context, tx Tx, d dbenv.Dialect, tableName string, g rowGroup) error {
+	// rows without columns take no parameters at all.
+	batch := max(maxBulkArgs/max(len(g.columns), 1), 1)
+	for start := 0; start < len(g.rows); start += batch {
+		end := min(start+batch, len(g.rows))
+
+		args := make([]any, 0, (end-start)*len(g.columns))
+		for _, row := range g.rows[start:end] {
+			args = append(args, row...)
+		}
+
+		query := d.BulkInsert(tableName, g.columns, end-start)
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return g.wrap(start, end, &dbenv.QueryError{Query: query, Err: err})
+		}
+	}
+
+	return nil
+}
+
+func sameColumns(row dbenv.TableRow, columns []string) bool {
+	if len(row) != len(columns) {
+		return false
+	}
+	for _, column := range columns {
+		if _, ok := row[column]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CoerceRow checks that every column of the row exists in table schema, and
+// converts values to types, which are expected by database driver for column
+// type.
+func CoerceRow(schema dbenv.TableSchema, row dbenv.TableRow) (dbenv.TableRow, error) {
+	types := schema.TypeMap()
+
+	res := make(dbenv.TableRow, len(row))
+	for column, value := range row {
+		typ, ok := types[column]
+		if !ok {
+			return nil, fmt.Errorf("column %q: not exists in table", column)
+		}
+
+		v, err := coerceValue(typ, value)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", column, err)
+		}
+		res[column] = v
+	}
+
+	return res, nil
+}
+
+func coerceValue(typ string, value driver.Value) (driver.Value, error) {
+	switch value.(type) {
+	case map[string]any, []any:
+		// nested values of json and yaml fixtures are documents of json
+		// columns.
+		if typ != "json" && typ != "jsonb" {
+			return nil, fmt.Errorf("nested value %v is allowed only for json columns, got %v", value, typ)
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+
+	v, err := driver.DefaultParameterConverter.ConvertValue(value)
+	if err != nil || v == nil {
+		return v, err
+	}
+
+	switch typ {
+	case "smallint", "integer", "bigint":
+		switch v := v.(type) {
+		case int64:
+			return v, nil
+		case float64:
+			if v == math.Trunc(v) {
+				return int64(v), nil
+			}
+		case string:
+			return strconv.ParseInt(v, 10, 64)
+		}
+	case "real", "double precision":
+		switch v := v.(type) {
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case string:
+			return strconv.ParseFloat(v, 64)
+		}
+	case "boolean":
+		return driver.Bool.ConvertValue(v)
+	default:
+		// other types are parsed by database itself
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("can't convert %#v to %v", v, typ)
 }
@@ -0,0 +1,156 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/internal/fakedb"
+)
+
+func TestTables(t *testing.T) {
+	d := fakedb.Dialect{Schema: map[string]dbenv.TableSchema{
+		"public.users":             {PrimaryKeys: []string{"id"}},
+		"billing.users":            {PrimaryKeys: []string{"user_id"}},
+		"billing.invoices":         {ForeignKeys: []dbenv.ForeignKey{{Columns: []string{"user_id"}, RefTable: "public.users", RefColumns: []string{"id"}}}},
+		"public.schema_migrations": {},
+	}}
+
+	got, schemas, err := Tables(context.Background(), nil, d, dbenv.Schemas{Exclude: []string{"*.schema_migrations"}})
+	require.NoError(t, err)
+	require.Equal(t, "public", schemas.Default)
+	require.Equal(t, map[string]dbenv.TableSchema{
+		"users":            {PrimaryKeys: []string{"id"}},
+		"billing.users":    {PrimaryKeys: []string{"user_id"}},
+		"billing.invoices": {ForeignKeys: []dbenv.ForeignKey{{Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}}}},
+	}, got)
+
+	got, _, err = Tables(context.Background(), nil, d, dbenv.Schemas{Default: "billing", Include: []string{"billing.*"}})
+	require.NoError(t, err)
+	require.Equal(t, map[string]dbenv.TableSchema{
+		"users":    {PrimaryKeys: []string{"user_id"}},
+		"invoices": {ForeignKeys: []dbenv.ForeignKey{{Columns: []string{"user_id"}, RefTable: "public.users", RefColumns: []string{"id"}}}},
+	}, got)
+}
+
+func TestGroupRows(t *testing.T) {
+	schema := dbenv.TableSchema{Types: []dbenv.ColumnType{
+		{Name: "id", Typ: "integer"},
+		{Name: "name", Typ: "text"},
+	}}
+
+	got, err := groupRows(schema, []dbenv.TableRow{
+		{"id": 1, "name": "a"},
+		{"id": "2", "name": "b"},
+		{"id": 3},
+		{"id": 4, "name": "d"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []rowGroup{
+		{first: 0, columns: []string{"id", "name"}, rows: [][]any{{int64(1), "a"}, {int64(2), "b"}}},
+		{first: 2, columns: []string{"id"}, rows: [][]any{{int64(3)}}},
+		{first: 3, columns: []string{"id", "name"}, rows: [][]any{{int64(4), "d"}}},
+	}, got)
+	require.EqualError(t, got[0].wrap(0, 2, errors.New("oops")), "rows 0-1: oops")
+	require.EqualError(t, got[2].wrap(0, 1, errors.New("oops")), "row 3: oops")
+
+	_, err = groupRows(schema, []dbenv.TableRow{{"id": 1}, {"id": "x"}})
+	require.EqualError(t, err, `row 1: column "id": strconv.ParseInt: parsing "x": invalid syntax`)
+}
+
+func TestClearedTables(t *testing.T) {
+	tables := map[string]dbenv.TableSchema{
+		"users":    {},
+		"kinds":    {},
+		"groups":   {ForeignKeys: []dbenv.ForeignKey{{RefTable: "users"}}},
+		"members":  {ForeignKeys: []dbenv.ForeignKey{{RefTable: "groups"}, {RefTable: "kinds"}}},
+		"invoices": {ForeignKeys: []dbenv.ForeignKey{{RefTable: "users"}}},
+	}
+	schemas := dbenv.Schemas{Default: "public"}
+	data := map[string][]dbenv.TableRow{"users": nil}
+
+	for _, tt := range []struct {
+		name   string
+		policy dbenv.FlushPolicy
+		want   map[string]bool
+	}{{
+		name: "All",
+		want: map[string]bool{"users": true, "kinds": true, "groups": true, "members": true, "invoices": true},
+	}, {
+		name:   "Listed",
+		policy: dbenv.FlushPolicy{Mode: dbenv.FlushListed},
+		want:   map[string]bool{"users": true},
+	}, {
+		name:   "Cascade",
+		policy: dbenv.FlushPolicy{Mode: dbenv.FlushCascade},
+		want:   map[string]bool{"users": true, "groups": true, "members": true, "invoices": true},
+	}, {
+		name:   "Cascade stops at protected",
+		policy: dbenv.FlushPolicy{Mode: dbenv.FlushCascade, Protected: []string{"public.groups"}},
+		want:   map[string]bool{"users": true, "invoices": true},
+	}, {
+		name:   "All except protected",
+		policy: dbenv.FlushPolicy{Protected: []string{"public.kinds"}},
+		want:   map[string]bool{"users": true, "groups": true, "members": true, "invoices": true},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, clearedTables(tables, schemas, tt.policy, data))
+		})
+	}
+}
+
+func TestReferencedTables(t *testing.T) {
+	tables := map[string]dbenv.TableSchema{
+		"users":         {},
+		"groups":        {ForeignKeys: []dbenv.ForeignKey{{RefTable: "users"}}},
+		"members":       {ForeignKeys: []dbenv.ForeignKey{{RefTable: "groups"}}},
+		"audit.entries": {ForeignKeys: []dbenv.ForeignKey{{RefTable: "users"}}},
+	}
+
+	require.Empty(t, referencedTables(tables, map[string]dbenv.TableSchema{
+		"users": {}, "groups": {}, "members": {}, "audit.entries": {},
+	}))
+	require.Equal(t, []string{"groups", "users"}, referencedTables(tables, map[string]dbenv.TableSchema{
+		"users": {}, "groups": {},
+	}))
+}
+
+func TestCoerceRowNested(t *testing.T) {
+	schema := dbenv.TableSchema{Types: []dbenv.ColumnType{
+		{Name: "meta", Typ: "jsonb"},
+		{Name: "name", Typ: "text"},
+	}}
+
+	row, err := CoerceRow(schema, dbenv.TableRow{"meta": map[string]any{"tags": []any{"a"}}})
+	require.NoError(t, err)
+	require.Equal(t, dbenv.TableRow{"meta": `{"tags":["a"]}`}, row)
+
+	_, err = CoerceRow(schema, dbenv.TableRow{"name": []any{"a"}})
+	require.EqualError(t, err, `column "name": nested value [a] is allowed only for json columns, got text`)
+}
+
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n++; w.n > 1 {
+		return 0, fmt.Errorf("write #%v failed", w.n)
+	}
+
+	return len(p), nil
+}
+
+func TestLogConsumer(t *testing.T) {
+	l := &LogConsumer{W: &failingWriter{}}
+
+	l.Accept(testcontainers.Log{Content: []byte("first")})
+	require.NoError(t, l.Err())
+
+	l.Accept(testcontainers.Log{Content: []byte("second")})
+	l.Accept(testcontainers.Log{Content: []byte("third")})
+	require.EqualError(t, l.Err(), "writing container logs: write #2 failed")
+}
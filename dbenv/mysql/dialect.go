@@ -0,0 +1,81 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"strconv"
+	"strings"
+
+	"github.com/quenbyako/ext/slices"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+type dialect struct{}
+
+var (
+	_ dbenv.Dialect             = dialect{}
+	_ dbenv.ForeignKeysDisabler = dialect{}
+	_ dbenv.ValueDecoder        = dialect{}
+)
+
+// Dialect returns MySQL SQL dialect.
+func Dialect() dbenv.Dialect { return dialect{} }
+
+func (dialect) QuoteIdent(name string) string { return quoteIdent(name) }
+
+func (dialect) Placeholder(int) string { return "?" }
+
+func (dialect) Tables(ctx context.Context, q dbenv.Queryer) (map[string]dbenv.TableSchema, error) {
+	return getAllSchemaTables(ctx, q)
+}
+
+func (dialect) CurrentSchema(ctx context.Context, q dbenv.Queryer) (string, error) {
+	return currentSchema(ctx, q)
+}
+
+// Truncate uses DELETE, since TRUNCATE commits transaction implicitly. Foreign
+// keys are not checked for the rest of the transaction, so rows can be
+// inserted in any order.
+func (d dialect) Truncate(tables []string) []string {
+	return append([]string{disableForeignKeysQuery}, slices.Remap(tables, func(s string) string {
+		return "DELETE FROM " + dbenv.QuoteName(d, s)
+	})...)
+}
+
+// DisablesForeignKeys is true, since Truncate turns FOREIGN_KEY_CHECKS off.
+func (dialect) DisablesForeignKeys() bool { return true }
+
+func (d dialect) BulkInsert(table string, columns []string, rows int) string {
+	return dbenv.BulkInsert(d, table, columns, rows)
+}
+
+// DecodeValue converts values of text protocol, which are always []byte, by
+// column type: integers to int64, floating point numbers to float64, binary
+// columns stay []byte, and everything else (including DECIMAL, so it's not
+// rounded) becomes string.
+func (dialect) DecodeValue(dbType string, v driver.Value) (driver.Value, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return v, nil
+	}
+
+	switch dbType = strings.TrimPrefix(dbType, "UNSIGNED "); dbType {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR":
+		i, err := strconv.ParseInt(string(b), 10, 64)
+		if err != nil {
+			// unsigned BIGINT may not fit into int64.
+			if _, uErr := strconv.ParseUint(string(b), 10, 64); uErr == nil {
+				return string(b), nil
+			}
+			return nil, err
+		}
+		return i, nil
+	case "FLOAT", "DOUBLE":
+		return strconv.ParseFloat(string(b), 64)
+	case "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "BIT", "GEOMETRY":
+		return b, nil
+	default:
+		return string(b), nil
+	}
+}
@@ -0,0 +1,176 @@
+// Package mysql implements dbenv.Container for MySQL and MariaDB.
+//
+// Package doesn't import any driver, so github.com/go-sql-driver/mysql must be
+// imported by caller. MariaDB is started with the same options, image can be
+// replaced with testcontainers.WithImage("mariadb:11"), since it accepts all
+// MYSQL_* environment variables.
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/dbenv/internal/util"
+)
+
+const (
+	defaultUser       = "test"
+	defaultPassword   = "test"
+	defaultDatabase   = "test"
+	defaultMySQLImage = "mysql:8.0"
+
+	driverName = "mysql"
+)
+
+// container represents the mysql container type used in the module
+type container struct {
+	testcontainers.Container
+	flush flushConfig
+}
+
+var (
+	_ dbenv.Container     = (*container)(nil)
+	_ dbenv.Terminator    = (*container)(nil)
+	_ dbenv.ContextCloser = (*container)(nil)
+	_ dbenv.LogStreamer   = (*container)(nil)
+)
+
+// New creates an instance of the mysql container type
+func New(ctx context.Context, opts ...testcontainers.ContainerCustomizer) (_ dbenv.Container, err error) {
+	req := testcontainers.ContainerRequest{
+		Image: defaultMySQLImage,
+		Env: map[string]string{
+			"MYSQL_ROOT_PASSWORD": defaultPassword,
+			"MYSQL_USER":          defaultUser,
+			"MYSQL_PASSWORD":      defaultPassword,
+			"MYSQL_DATABASE":      defaultDatabase,
+		},
+		ExposedPorts: []string{"3306/tcp"},
+		Cmd:          []string{"mysqld", "--innodb-flush-log-at-trx-commit=0"},
+	}
+
+	genericContainerReq := testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	}
+
+	var flush flushConfig
+	for _, opt := range opts {
+		if opt, ok := opt.(FlushOption); ok {
+			opt(&flush)
+		}
+		opt.Customize(&genericContainerReq)
+	}
+
+	var args url.Values
+	if argsRaw, ok := req.Env["MYSQL_CONN_ARGS"]; ok {
+		if args, err = url.ParseQuery(argsRaw); err != nil {
+			return nil, fmt.Errorf("invalid connection args: %w", err)
+		}
+	}
+	withWaitSQL(req.Env["MYSQL_DATABASE"], args).Customize(&genericContainerReq)
+
+	c, err := testcontainers.GenericContainer(ctx, genericContainerReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return &container{Container: c, flush: flush}, nil
+}
+
+// NewT creates mysql container for the test, which is terminated on test
+// cleanup. See dbenv.NewT for details.
+func NewT(t testing.TB, opts ...testcontainers.ContainerCustomizer) dbenv.Container {
+	t.Helper()
+
+	return dbenv.NewT(t, New, opts...)
+}
+
+const defaultStopTimeout = 5 * time.Second
+
+func (c *container) Close() error {
+	return c.CloseContext(context.Background())
+}
+
+// CloseContext stops the container, giving it defaultStopTimeout to stop
+// gracefully, or less, if ctx deadline is closer.
+func (c *container) CloseContext(ctx context.Context) error {
+	return util.StopContainer(ctx, c.Container, defaultStopTimeout)
+}
+
+func (c *container) ConnString(ctx context.Context) (string, error) {
+	return hostFromEnv(ctx, c.Container)
+}
+
+func (c *container) Flush(ctx context.Context, data map[string][]dbenv.TableRow) error {
+	db, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = util.Flush(ctx, db, dialect{}, c.flush.schemas, c.flush.policy, data)
+	return err
+}
+
+func (c *container) Dump(ctx context.Context) (map[string]dbenv.TableData, error) {
+	db, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return util.Dump(ctx, db, dialect{}, c.flush.schemas)
+}
+
+func (c *container) Query(ctx context.Context, query string, args ...any) ([]dbenv.TableRow, error) {
+	db, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return util.QueryRows(ctx, db, dialect{}, query, args...)
+}
+
+func (c *container) connect(ctx context.Context) (*sql.DB, error) {
+	connString, err := hostFromEnv(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return util.ConnectSQLContext(ctx, driverName, connString)
+}
+
+// foreign_key_checks is a session variable, so connection must be pinned,
+// while it's disabled.
+const disableForeignKeysQuery = "SET FOREIGN_KEY_CHECKS = 0"
+
+func disableForeignKeys(ctx context.Context, conn util.Tx) error {
+	if _, err := conn.ExecContext(ctx, disableForeignKeysQuery); err != nil {
+		return &dbenv.QueryError{Query: disableForeignKeysQuery, Err: err}
+	}
+
+	return nil
+}
+
+func (c *container) StreamLogs(ctx context.Context, w io.Writer) (stop func() error, err error) {
+	consumer := &util.LogConsumer{W: w}
+	c.Container.FollowOutput(consumer)
+	if err := c.Container.StartLogProducer(ctx); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return errors.Join(c.Container.StopLogProducer(), consumer.Err())
+	}, nil
+}
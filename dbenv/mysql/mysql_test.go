@@ -0,0 +1,94 @@
+package mysql_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/dbenv/mysql"
+	"github.com/quenbyako/sqltest/tabsync"
+)
+
+func TestContainer(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	c := mysql.NewT(t, mysql.WithSetupSchema([]string{
+		// names goes first to check, that foreign keys are not checked
+		"CREATE TABLE names (id INT PRIMARY KEY, name TEXT, group_id INT, FOREIGN KEY (group_id) REFERENCES `groups` (id))",
+		"CREATE TABLE `groups` (id INT PRIMARY KEY, name TEXT)",
+	}))
+
+	err := tabsync.FlushRaw(c, map[string][]map[string]driver.Value{
+		"names": {
+			{"id": 1, "name": "John", "group_id": 1},
+			{"id": 2, "name": "Jane", "group_id": 2},
+		},
+		"groups": {
+			{"id": 1, "name": "Admins"},
+			{"id": 2, "name": "Users"},
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, tabsync.ValidateResultCSV(c,
+		"SELECT n.name, g.name AS `group` FROM names n JOIN `groups` g ON g.id = n.group_id ORDER BY n.id",
+		strings.NewReader("name,group\nJohn,Admins\nJane,Users\n"),
+	))
+
+	dump, err := c.Dump(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"id"}, dump["names"].Schema.PrimaryKeys)
+	require.Len(t, dump["names"].Schema.ForeignKeys, 1)
+	require.Len(t, dump["groups"].Rows, 2)
+}
+
+func TestFlushCycle(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	c := mysql.NewT(t, mysql.WithSetupSchema([]string{
+		"CREATE TABLE a (id INT PRIMARY KEY, b_id INT, FOREIGN KEY (b_id) REFERENCES b (id))",
+		"CREATE TABLE b (id INT PRIMARY KEY, a_id INT, FOREIGN KEY (a_id) REFERENCES a (id))",
+	}))
+
+	// foreign keys are not checked by Flush, so cycle doesn't matter.
+	err := tabsync.FlushRaw(c, map[string][]map[string]driver.Value{
+		"a": {{"id": 1, "b_id": 1}},
+		"b": {{"id": 1, "a_id": 1}},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, tabsync.ValidateResultCSV(c,
+		"SELECT a.id AS a, b.id AS b FROM a JOIN b ON b.id = a.b_id AND a.id = b.a_id",
+		strings.NewReader("a,b\n1,1\n"),
+	))
+}
+
+func TestDecodeValue(t *testing.T) {
+	decoder := mysql.Dialect().(dbenv.ValueDecoder)
+
+	for _, tt := range []struct {
+		dbType string
+		value  driver.Value
+		want   driver.Value
+	}{
+		{dbType: "INT", value: []byte("-42"), want: int64(-42)},
+		{dbType: "UNSIGNED BIGINT", value: []byte("18446744073709551615"), want: "18446744073709551615"},
+		{dbType: "DOUBLE", value: []byte("1.5"), want: 1.5},
+		{dbType: "DECIMAL", value: []byte("1.50"), want: "1.50"},
+		{dbType: "VARCHAR", value: []byte("John"), want: "John"},
+		{dbType: "JSON", value: []byte(`{"a":1}`), want: `{"a":1}`},
+		{dbType: "BLOB", value: []byte{0, 1}, want: []byte{0, 1}},
+		{dbType: "INT", value: int64(1), want: int64(1)},
+		{dbType: "TEXT", value: nil, want: nil},
+	} {
+		got, err := decoder.DecodeValue(tt.dbType, tt.value)
+		require.NoError(t, err, tt.dbType)
+		require.Equal(t, tt.want, got, tt.dbType)
+	}
+}
@@ -0,0 +1,139 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/testcontainers/testcontainers-go"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/dbenv/internal/util"
+)
+
+// WithConnArgs sets go-sql-driver/mysql parameters of the connection string,
+// e.g. parseTime or multiStatements.
+func WithConnArgs(args url.Values) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) {
+		req.Env["MYSQL_CONN_ARGS"] = args.Encode()
+	}
+}
+
+// WithConfigFile sets the config file to be used for the mysql container. It
+// is mounted to conf.d directory, so it's merged with default configuration.
+func WithConfigFile(cfg string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) {
+		cfgFile := testcontainers.ContainerFile{
+			HostFilePath:      cfg,
+			ContainerFilePath: "/etc/mysql/conf.d/custom.cnf",
+			FileMode:          0o644,
+		}
+
+		req.Files = append(req.Files, cfgFile)
+	}
+}
+
+// WithInitScripts sets the init scripts to be run when the container starts
+func WithInitScripts(scripts ...string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) {
+		initScripts := []testcontainers.ContainerFile{}
+		for _, script := range scripts {
+			cf := testcontainers.ContainerFile{
+				HostFilePath:      script,
+				ContainerFilePath: "/docker-entrypoint-initdb.d/" + filepath.Base(script),
+				FileMode:          0o755,
+			}
+			initScripts = append(initScripts, cf)
+		}
+		req.Files = append(req.Files, initScripts...)
+	}
+}
+
+// WithSetupSchema runs queries right after container start. Each query must
+// contain single statement, unless multiStatements is set with WithConnArgs.
+func WithSetupSchema(queries []string) testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) {
+		req.LifecycleHooks = append(req.LifecycleHooks, testcontainers.ContainerLifecycleHooks{
+			PostStarts: []testcontainers.ContainerHook{
+				func(ctx context.Context, c testcontainers.Container) error {
+					connString, err := hostFromEnv(ctx, c)
+					if err != nil {
+						return err
+					}
+
+					db, err := util.ConnectSQLContext(ctx, driverName, connString)
+					if err != nil {
+						return err
+					}
+					defer db.Close()
+
+					if err := setupSchema(ctx, db, queries); err != nil {
+						return err
+					}
+
+					logger := req.Logger
+					if logger == nil {
+						logger = testcontainers.Logger
+					}
+					logger.Printf("🎉 Testing environment is setted up!")
+
+					return nil
+				},
+			},
+		})
+	}
+}
+
+// FlushOption configures Flush and Dump. It doesn't affect container itself,
+// it's picked by New.
+type FlushOption func(*flushConfig)
+
+type flushConfig struct {
+	schemas dbenv.Schemas
+	policy  dbenv.FlushPolicy
+}
+
+func (FlushOption) Customize(*testcontainers.GenericContainerRequest) {}
+
+// WithSchemas selects tables, which are visible to Flush and Dump, and sets
+// default database of unqualified table names. See dbenv.Schemas for details.
+func WithSchemas(schemas dbenv.Schemas) FlushOption {
+	return func(c *flushConfig) { c.schemas = schemas }
+}
+
+// WithFlushMode sets dbenv.FlushMode of Flush.
+// Foreign keys are not checked by Flush, so rows of tables, which are not
+// cleared, may keep references to removed rows.
+func WithFlushMode(mode dbenv.FlushMode) FlushOption {
+	return func(c *flushConfig) { c.policy.Mode = mode }
+}
+
+// WithProtected sets dbenv.FlushPolicy.Protected patterns of tables.
+func WithProtected(patterns ...string) FlushOption {
+	return func(c *flushConfig) { c.policy.Protected = append(c.policy.Protected, patterns...) }
+}
+
+func setupSchema(ctx context.Context, db *sql.DB, queries []string) error {
+	// session settings are applied only to single connection, so it must be
+	// pinned.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", dbenv.ErrConnection, err)
+	}
+	defer conn.Close()
+
+	// tables may be created in any order, so references are not checked
+	if err := disableForeignKeys(ctx, conn); err != nil {
+		return err
+	}
+
+	for _, query := range queries {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("setting up schema: %w", &dbenv.QueryError{Query: query, Err: err})
+		}
+	}
+
+	return nil
+}
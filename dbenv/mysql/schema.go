@@ -0,0 +1,229 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+// every database is a schema in mysql, so all of them are introspected,
+// except system ones.
+const tableColumnsQuery = `
+SELECT
+	columns.table_schema,
+	columns.table_name,
+	columns.column_name,
+	columns.data_type,
+	columns.column_type,
+	columns.is_nullable = 'YES',
+	COALESCE(columns.column_default, ''),
+	columns.extra LIKE '%auto_increment%'
+FROM information_schema.columns AS columns
+JOIN information_schema.tables AS tables ON
+	tables.table_schema = columns.table_schema AND
+	tables.table_name = columns.table_name
+WHERE
+	columns.table_schema NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys') AND
+	tables.table_type = 'BASE TABLE'
+ORDER BY columns.table_schema, columns.table_name, columns.ordinal_position
+`
+
+const keyColumnsQuery = `
+SELECT
+	keys.table_schema,
+	keys.table_name,
+	keys.constraint_name,
+	keys.column_name,
+	COALESCE(keys.referenced_table_schema, ''),
+	COALESCE(keys.referenced_table_name, ''),
+	COALESCE(keys.referenced_column_name, ''),
+	COALESCE(refs.delete_rule, ''),
+	COALESCE(refs.update_rule, '')
+FROM information_schema.key_column_usage AS keys
+LEFT JOIN information_schema.referential_constraints AS refs ON
+	refs.constraint_schema = keys.constraint_schema AND
+	refs.constraint_name = keys.constraint_name AND
+	refs.table_name = keys.table_name
+WHERE
+	keys.table_schema NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys') AND
+	(keys.constraint_name = 'PRIMARY' OR keys.referenced_table_name IS NOT NULL)
+ORDER BY keys.table_schema, keys.table_name, keys.constraint_name, keys.ordinal_position
+`
+
+// unique constraints are unique indexes in mysql. Prefix indexes are skipped,
+// since they don't guarantee uniqueness of the whole value.
+const uniquesQuery = `
+SELECT table_schema, table_name, index_name, column_name
+FROM information_schema.statistics
+WHERE
+	table_schema NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys') AND
+	non_unique = 0 AND
+	index_name <> 'PRIMARY' AND
+	column_name IS NOT NULL AND
+	sub_part IS NULL
+ORDER BY table_schema, table_name, index_name, seq_in_index
+`
+
+// getAllSchemaTables returns the same schema, as postgres introspection does,
+// so fixtures and validators work equally for both databases. Tables are keyed
+// by qualified name, e.g. "test.users".
+func getAllSchemaTables(ctx context.Context, tx dbenv.Queryer) (map[string]dbenv.TableSchema, error) {
+	res := make(map[string]dbenv.TableSchema)
+	if err := scanRows(ctx, tx, tableColumnsQuery, func(scan func(...any) error) error {
+		var schema, table, column, dataType, columnType, defaultValue string
+		var nullable, identity bool
+		if err := scan(&schema, &table, &column, &dataType, &columnType, &nullable, &defaultValue, &identity); err != nil {
+			return err
+		}
+		table = schema + "." + table
+
+		info := res[table]
+		info.Types = append(info.Types, dbenv.ColumnType{
+			Name:     column,
+			Typ:      normalizeType(dataType, columnType),
+			Nullable: nullable,
+			Default:  defaultValue,
+			Identity: identity,
+		})
+		res[table] = info
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	type fkKey struct{ table, name string }
+	foreignKeys := make(map[fkKey]int) // index in TableSchema.ForeignKeys
+	if err := scanRows(ctx, tx, keyColumnsQuery, func(scan func(...any) error) error {
+		var schema, table, constraint, column, refSchema, refTable, refColumn, onDelete, onUpdate string
+		if err := scan(&schema, &table, &constraint, &column, &refSchema, &refTable, &refColumn, &onDelete, &onUpdate); err != nil {
+			return err
+		}
+		table = schema + "." + table
+
+		info, ok := res[table]
+		if !ok {
+			return nil
+		}
+
+		if constraint == "PRIMARY" {
+			info.PrimaryKeys = append(info.PrimaryKeys, column)
+		} else {
+			i, ok := foreignKeys[fkKey{table, constraint}]
+			if !ok {
+				i = len(info.ForeignKeys)
+				foreignKeys[fkKey{table, constraint}] = i
+				info.ForeignKeys = append(info.ForeignKeys, dbenv.ForeignKey{
+					Name:     constraint,
+					RefTable: refSchema + "." + refTable,
+					OnDelete: onDelete,
+					OnUpdate: onUpdate,
+				})
+			}
+			info.ForeignKeys[i].Columns = append(info.ForeignKeys[i].Columns, column)
+			info.ForeignKeys[i].RefColumns = append(info.ForeignKeys[i].RefColumns, refColumn)
+		}
+		res[table] = info
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := scanRows(ctx, tx, uniquesQuery, func(scan func(...any) error) error {
+		var schema, table, index, column string
+		if err := scan(&schema, &table, &index, &column); err != nil {
+			return err
+		}
+		table = schema + "." + table
+
+		info, ok := res[table]
+		if !ok {
+			return nil
+		}
+
+		if n := len(info.Uniques); n == 0 || info.Uniques[n-1].Name != index {
+			info.Uniques = append(info.Uniques, dbenv.Unique{Name: index})
+		}
+		info.Uniques[len(info.Uniques)-1].Columns = append(info.Uniques[len(info.Uniques)-1].Columns, column)
+		res[table] = info
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func currentSchema(ctx context.Context, tx dbenv.Queryer) (string, error) {
+	const query = "SELECT COALESCE(DATABASE(), '')"
+
+	var res string
+	if err := scanRows(ctx, tx, query, func(scan func(...any) error) error { return scan(&res) }); err != nil {
+		return "", err
+	}
+
+	return res, nil
+}
+
+func scanRows(ctx context.Context, tx dbenv.Queryer, query string, f func(scan func(...any) error) error) error {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return introspectionErr(query, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := f(rows.Scan); err != nil {
+			return introspectionErr(query, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return introspectionErr(query, err)
+	}
+
+	return nil
+}
+
+func introspectionErr(query string, err error) error {
+	return fmt.Errorf("%w: %w", dbenv.ErrIntrospection, &dbenv.QueryError{Query: query, Err: err})
+}
+
+// normalizeType converts mysql column type to the postgres one. dataType is
+// the bare type name, e.g. "tinyint", and columnType is the full definition,
+// e.g. "tinyint(1) unsigned".
+func normalizeType(dataType, columnType string) string {
+	dataType = strings.ToLower(dataType)
+
+	switch dataType {
+	case "tinyint":
+		// mysql has no boolean type, BOOL is an alias for TINYINT(1)
+		if strings.HasPrefix(strings.ToLower(columnType), "tinyint(1)") {
+			return "boolean"
+		}
+		return "smallint"
+	case "smallint", "bigint", "date", "json":
+		return dataType
+	case "int", "integer", "mediumint", "year":
+		return "integer"
+	case "float":
+		return "real"
+	case "double", "real":
+		return "double precision"
+	case "decimal", "numeric":
+		return "numeric"
+	case "datetime", "timestamp":
+		return "timestamp"
+	case "time":
+		return "time"
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext", "enum", "set":
+		return "text"
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob", "bit":
+		return "bytea"
+	default:
+		return dataType
+	}
+}
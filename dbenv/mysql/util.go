@@ -0,0 +1,89 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/dbenv/internal/util"
+)
+
+// myEnv is everything required to connect to the database of the container.
+type myEnv struct {
+	user, password string
+	host           string
+	port           int
+	dbName         string // database, created on startup
+	args           url.Values
+}
+
+func (e myEnv) connString() string {
+	return mysqlHost(e.user, e.password, e.host, e.port, e.dbName, e.args)
+}
+
+func envFromContainer(ctx context.Context, c testcontainers.Container) (myEnv, error) {
+	env, err := util.GetContainerEnv(ctx, c)
+	if err != nil {
+		return myEnv{}, fmt.Errorf("%w: %w", dbenv.ErrConnection, err)
+	}
+
+	host, port, err := util.ContainerHostPort(ctx, c, "3306/tcp")
+	if err != nil {
+		return myEnv{}, fmt.Errorf("%w: %w", dbenv.ErrConnection, err)
+	}
+
+	var args url.Values
+	if argsRaw, ok := env["MYSQL_CONN_ARGS"]; ok {
+		if args, err = url.ParseQuery(argsRaw); err != nil {
+			return myEnv{}, fmt.Errorf("%w: invalid connection args: %w", dbenv.ErrConnection, err)
+		}
+	}
+
+	return myEnv{
+		user:     env["MYSQL_USER"],
+		password: env["MYSQL_PASSWORD"],
+		host:     host,
+		port:     port,
+		dbName:   env["MYSQL_DATABASE"],
+		args:     args,
+	}, nil
+}
+
+func hostFromEnv(ctx context.Context, c testcontainers.Container) (connStr string, err error) {
+	env, err := envFromContainer(ctx, c)
+	if err != nil {
+		return "", err
+	}
+
+	return env.connString(), nil
+}
+
+// quoteIdent quotes identifier, so it can be used in queries as is.
+func quoteIdent(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
+// mysqlHost builds connection string in go-sql-driver/mysql format, e.g.
+// "user:password@tcp(localhost:3306)/db?parseTime=true".
+func mysqlHost(user, password, host string, port int, dbName string, opts url.Values) string {
+	res := fmt.Sprintf("%v:%v@tcp(%v:%v)/%v", user, password, host, port, dbName)
+	if len(opts) > 0 {
+		res += "?" + opts.Encode()
+	}
+
+	return res
+}
+
+func withWaitSQL(dbName string, args url.Values) testcontainers.ContainerCustomizer {
+	return testcontainers.WithWaitStrategy(
+		wait.ForSQL("3306/tcp", driverName, func(host string, port nat.Port) string {
+			return mysqlHost(defaultUser, defaultPassword, host, port.Int(), dbName, args)
+		}),
+	)
+}
@@ -0,0 +1,39 @@
+package mysql
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMySQLHost(t *testing.T) {
+	require.Equal(t, "user:pass@tcp(localhost:3306)/test", mysqlHost("user", "pass", "localhost", 3306, "test", nil))
+	require.Equal(t, "user:pass@tcp(localhost:3306)/test?parseTime=true",
+		mysqlHost("user", "pass", "localhost", 3306, "test", url.Values{"parseTime": {"true"}}))
+}
+
+func TestQuoteIdent(t *testing.T) {
+	require.Equal(t, "`order`", quoteIdent("order"))
+	require.Equal(t, "`a``b`", quoteIdent("a`b"))
+}
+
+func TestNormalizeType(t *testing.T) {
+	for _, tt := range []struct {
+		dataType, columnType string
+		want                 string
+	}{
+		{"tinyint", "tinyint(1)", "boolean"},
+		{"tinyint", "tinyint(4) unsigned", "smallint"},
+		{"int", "int(11)", "integer"},
+		{"bigint", "bigint(20)", "bigint"},
+		{"varchar", "varchar(255)", "text"},
+		{"enum", "enum('a','b')", "text"},
+		{"double", "double", "double precision"},
+		{"datetime", "datetime(6)", "timestamp"},
+		{"longblob", "longblob", "bytea"},
+		{"geometry", "geometry", "geometry"},
+	} {
+		require.Equal(t, tt.want, normalizeType(tt.dataType, tt.columnType), tt.columnType)
+	}
+}
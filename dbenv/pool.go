@@ -0,0 +1,98 @@
+package dbenv
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Pool hands out isolated databases, cloned from the same snapshot, so tests,
+// which are running in parallel, don't interfere with each other.
+type Pool struct {
+	c        Snapshotter
+	snapshot string
+	sem      chan struct{} // nil means unlimited
+}
+
+// NewPool creates pool of databases, cloned from the snapshot of the
+// container. At most maxConcurrency databases can be acquired at the same
+// time, zero or negative value means no limit.
+func NewPool(c Container, snapshot string, maxConcurrency int) (*Pool, error) {
+	s, ok := c.(Snapshotter)
+	if !ok {
+		return nil, fmt.Errorf("container %T doesn't support snapshots", c)
+	}
+
+	p := &Pool{c: s, snapshot: snapshot}
+	if maxConcurrency > 0 {
+		p.sem = make(chan struct{}, maxConcurrency)
+	}
+
+	return p, nil
+}
+
+// Acquire creates new isolated database, waiting until there is a free slot
+// in the pool. Returned container drops the database and frees the slot on
+// Close.
+func (p *Pool) Acquire(ctx context.Context) (Container, error) {
+	if p.sem != nil {
+		select {
+		case p.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	c, err := p.c.Clone(ctx, p.snapshot)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+
+	return &pooled{Container: c, release: p.release}, nil
+}
+
+// AcquireT works like Acquire, but returns database to the pool on test
+// cleanup, and fails the test on any error.
+func (p *Pool) AcquireT(t testing.TB) Container {
+	t.Helper()
+
+	ctx, cancel := testContext(t)
+	defer cancel()
+
+	c, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("can't acquire database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("can't return database to the pool: %v", err)
+		}
+	})
+
+	return c
+}
+
+func (p *Pool) release() {
+	if p.sem != nil {
+		<-p.sem
+	}
+}
+
+type pooled struct {
+	Container
+
+	once    sync.Once
+	release func()
+}
+
+func (p *pooled) Close() (err error) {
+	p.once.Do(func() {
+		defer p.release()
+		err = p.Container.Close()
+	})
+
+	return err
+}
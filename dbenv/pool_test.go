@@ -0,0 +1,40 @@
+package dbenv_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/internal/fakedb"
+)
+
+func TestPool(t *testing.T) {
+	s := &fakeSnapshotter{clones: map[string]*fakedb.Container{}}
+
+	_, err := dbenv.NewPool(&fakedb.Container{}, "setup", 1)
+	require.EqualError(t, err, "container *fakedb.Container doesn't support snapshots")
+
+	p, err := dbenv.NewPool(s, "setup", 1)
+	require.NoError(t, err)
+
+	first, err := p.Acquire(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = p.Acquire(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded, "pool must be limited")
+
+	require.NoError(t, first.Close())
+	require.True(t, s.clones["setup"].Closed)
+	require.NoError(t, first.Close(), "second close must be no-op")
+
+	t.Run("test", func(t *testing.T) {
+		p.AcquireT(t)
+		require.False(t, s.clones["setup"].Closed)
+	})
+	require.True(t, s.clones["setup"].Closed)
+}
@@ -0,0 +1,222 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/dbenv/internal/util"
+)
+
+const attachedPrefix = "sqltest_attached_"
+
+// AttachOption configures database, attached with Attach.
+type AttachOption func(*attachConfig)
+
+type attachConfig struct {
+	createDatabase bool
+	setupQueries   []string
+	flush          flushConfig
+}
+
+// AttachCreateDatabase creates new database on the server for the container,
+// instead of using database from connection string. Database is dropped on
+// Close. User must have CREATEDB privilege.
+func AttachCreateDatabase() AttachOption {
+	return func(c *attachConfig) { c.createDatabase = true }
+}
+
+// AttachSetupSchema runs queries right after database is attached, same as
+// WithSetupSchema does for docker containers, except that triggers and
+// foreign key checks are not disabled, since it requires superuser.
+func AttachSetupSchema(queries []string) AttachOption {
+	return func(c *attachConfig) { c.setupQueries = queries }
+}
+
+// AttachSchemas selects tables, which are visible to Flush and Dump, same as
+// WithSchemas does for docker containers.
+func AttachSchemas(schemas dbenv.Schemas) AttachOption {
+	return func(c *attachConfig) { c.flush.schemas = schemas }
+}
+
+// AttachFlush applies flush options, e.g. WithTruncate or WithIdentityStart,
+// to attached database.
+func AttachFlush(opts ...FlushOption) AttachOption {
+	return func(c *attachConfig) {
+		for _, opt := range opts {
+			opt(&c.flush)
+		}
+	}
+}
+
+// attached is an existing database, which is not managed by docker, e.g. CI
+// service container or local postgres instance.
+type attached struct {
+	dsn   string
+	flush flushConfig
+	// drop removes created database, it's nil when database from original
+	// connection string is used.
+	drop func(context.Context) error
+}
+
+var (
+	_ dbenv.Container     = (*attached)(nil)
+	_ dbenv.ContextCloser = (*attached)(nil)
+)
+
+// Attach creates container from the existing database, so the same fixtures
+// and validators can be used, when docker is not available. dsn is a
+// connection string in any format, that pgx supports.
+func Attach(ctx context.Context, dsn string, opts ...AttachOption) (_ dbenv.Container, err error) {
+	var cfg attachConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	a := &attached{dsn: dsn}
+	if cfg.createDatabase {
+		if a, err = attachCreated(ctx, dsn); err != nil {
+			return nil, err
+		}
+	}
+	a.flush = cfg.flush
+
+	if len(cfg.setupQueries) > 0 {
+		if err := a.setup(ctx, cfg.setupQueries); err != nil {
+			return nil, closeAfter(a, err)
+		}
+	}
+
+	return a, nil
+}
+
+func attachCreated(ctx context.Context, dsn string) (*attached, error) {
+	name := attachedPrefix + strings.ReplaceAll(uuid.NewString(), "-", "")
+	newDSN, err := withDatabase(dsn, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := execDSN(ctx, dsn, "CREATE DATABASE "+quoteIdent(name)); err != nil {
+		return nil, err
+	}
+
+	return &attached{
+		dsn: newDSN,
+		drop: func(ctx context.Context) error {
+			return execDSN(ctx, dsn, "DROP DATABASE IF EXISTS "+quoteIdent(name)+" WITH (FORCE)")
+		},
+	}, nil
+}
+
+func (a *attached) setup(ctx context.Context, queries []string) error {
+	conn, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// attached user is usually not a superuser, so queries are run with all
+	// checks, and must be ordered.
+	return setupSchema(ctx, conn, queries, false)
+}
+
+func (a *attached) ConnString(context.Context) (string, error) { return a.dsn, nil }
+
+// Close drops the database, if it was created by Attach, otherwise it does
+// nothing.
+func (a *attached) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStopTimeout)
+	defer cancel()
+
+	return a.CloseContext(ctx)
+}
+
+// CloseContext is Close limited by ctx.
+func (a *attached) CloseContext(ctx context.Context) error {
+	if a.drop == nil {
+		return nil
+	}
+
+	return a.drop(ctx)
+}
+
+func (a *attached) Flush(ctx context.Context, data map[string][]dbenv.TableRow) error {
+	conn, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return a.flush.flush(ctx, conn, data)
+}
+
+func (a *attached) Dump(ctx context.Context) (map[string]dbenv.TableData, error) {
+	conn, err := a.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return util.Dump(ctx, conn, a.flush.dialect, a.flush.schemas)
+}
+
+func (a *attached) Query(ctx context.Context, query string, args ...any) ([]dbenv.TableRow, error) {
+	conn, err := a.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return util.QueryRows(ctx, conn, a.flush.dialect, query, args...)
+}
+
+func (a *attached) connect(ctx context.Context) (*sql.DB, error) {
+	return util.ConnectSQLContext(ctx, "pgx", a.dsn)
+}
+
+func execDSN(ctx context.Context, dsn string, query string) error {
+	conn, err := util.ConnectSQLContext(ctx, "pgx", dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return execAll(ctx, conn, query)
+}
+
+func closeAfter(a *attached, err error) error {
+	if closeErr := a.Close(); closeErr != nil {
+		return fmt.Errorf("%w (closing: %v)", err, closeErr)
+	}
+
+	return err
+}
+
+// withDatabase replaces database name in connection string. Both url
+// ("postgres://user@host/db") and keyword/value ("host=localhost dbname=db")
+// formats are supported.
+func withDatabase(dsn, dbName string) (string, error) {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		// in keyword/value format the last value wins
+		return strings.TrimSpace(dsn) + " dbname=" + quoteDSNValue(dbName), nil
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid connection string: %w", dbenv.ErrConnection, err)
+	}
+	u.Path = "/" + dbName
+	u.RawPath = ""
+
+	return u.String(), nil
+}
+
+func quoteDSNValue(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+// BulkLoad loads rows with COPY protocol. COPY uses binary format, so values
+// are encoded by pgx on client side, and only values, which pgx can encode for
+// the column type, are supported. Everything else, e.g. dates written as
+// strings, is inserted with INSERT, so postgres parses them itself.
+func (d dialect) BulkLoad(ctx context.Context, conn *sql.Conn, table string, schema dbenv.TableSchema, columns []string, rows [][]any) (bool, error) {
+	if d.noCopy || len(columns) == 0 {
+		return false, nil
+	}
+
+	types := schema.TypeMap()
+	for _, row := range rows {
+		for i, v := range row {
+			if !copyable(types[columns[i]], v) {
+				return false, nil
+			}
+		}
+	}
+
+	var copied bool
+	err := conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			// connection is opened by some other driver, which is registered
+			// as "pgx".
+			return nil
+		}
+
+		copied = true
+		_, err := c.Conn().CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, pgx.CopyFromRows(rows))
+		return err
+	})
+	if err != nil {
+		return false, &dbenv.QueryError{Query: "COPY " + dbenv.QuoteName(d, table) + " FROM STDIN", Err: err}
+	}
+
+	return copied, nil
+}
+
+// copyable returns true, if coerced value can be encoded by pgx in binary
+// format for the column type.
+func copyable(typ string, v any) bool {
+	switch v.(type) {
+	case nil:
+		return true
+	case int64:
+		switch typ {
+		case "smallint", "integer", "bigint", "real", "double precision", "numeric":
+			return true
+		}
+	case float64:
+		switch typ {
+		case "real", "double precision", "numeric":
+			return true
+		}
+	case bool:
+		return typ == "boolean"
+	case string:
+		switch typ {
+		case "text", "character varying", "character", "uuid", "json", "jsonb":
+			return true
+		}
+	case []byte:
+		switch typ {
+		case "bytea", "json", "jsonb":
+			return true
+		}
+	case time.Time:
+		switch typ {
+		case "timestamp without time zone", "timestamp with time zone", "date":
+			return true
+		}
+	}
+
+	return false
+}
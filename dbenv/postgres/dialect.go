@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/quenbyako/ext/slices"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+type dialect struct {
+	truncate      bool
+	identityStart int64
+	noCopy        bool
+}
+
+var (
+	_ dbenv.Dialect             = dialect{}
+	_ dbenv.IdentityResetter    = dialect{}
+	_ dbenv.ReferencedTruncater = dialect{}
+	_ dbenv.BulkLoader          = dialect{}
+)
+
+// Dialect returns postgres SQL dialect.
+func Dialect() dbenv.Dialect { return dialect{} }
+
+func (dialect) QuoteIdent(name string) string { return quoteIdent(name) }
+
+func (dialect) Placeholder(i int) string { return "$" + strconv.Itoa(i) }
+
+func (dialect) Tables(ctx context.Context, q dbenv.Queryer) (map[string]dbenv.TableSchema, error) {
+	return getAllSchemaTables(ctx, q)
+}
+
+func (dialect) CurrentSchema(ctx context.Context, q dbenv.Queryer) (string, error) {
+	return currentSchema(ctx, q)
+}
+
+// Truncate uses DELETE instead of TRUNCATE by default, since TRUNCATE takes
+// exclusive lock and waits for every open transaction, which touched the
+// table.
+func (d dialect) Truncate(tables []string) []string {
+	quoted := slices.Remap(tables, func(s string) string { return dbenv.QuoteName(d, s) })
+	if !d.truncate {
+		return slices.Remap(quoted, func(s string) string { return "DELETE FROM " + s })
+	} else if len(tables) == 0 {
+		return nil
+	}
+
+	return []string{"TRUNCATE TABLE " + strings.Join(quoted, ", ") + " RESTART IDENTITY"}
+}
+
+// TruncateReferenced always uses DELETE, since postgres doesn't truncate
+// tables, which are referenced by tables, that are not truncated too. Identity
+// columns are restarted by ResetIdentity after that.
+func (dialect) TruncateReferenced(tables []string) []string {
+	return dialect{}.Truncate(tables)
+}
+
+// ResetIdentity moves sequence of serial or identity column right after the
+// maximum value of the column, or to identityStart, if it's greater.
+func (d dialect) ResetIdentity(table, column string) []string {
+	start := max(d.identityStart, 1)
+
+	return []string{fmt.Sprintf(
+		"SELECT setval(pg_get_serial_sequence(%v, %v), GREATEST(COALESCE(max(%v), 0) + 1, %v), false) FROM %v",
+		quoteLiteral(dbenv.QuoteName(d, table)), quoteLiteral(column), quoteIdent(column), start, dbenv.QuoteName(d, table),
+	)}
+}
+
+func (d dialect) BulkInsert(table string, columns []string, rows int) string {
+	return dbenv.BulkInsert(d, table, columns, rows)
+}
@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialectTruncate(t *testing.T) {
+	tables := []string{"public.names", "billing.groups"}
+
+	require.Equal(t, []string{
+		`DELETE FROM "public"."names"`,
+		`DELETE FROM "billing"."groups"`,
+	}, dialect{}.Truncate(tables))
+	require.Equal(t, []string{
+		`TRUNCATE TABLE "public"."names", "billing"."groups" RESTART IDENTITY`,
+	}, dialect{truncate: true}.Truncate(tables))
+	require.Empty(t, dialect{truncate: true}.Truncate(nil))
+	require.Equal(t, []string{
+		`DELETE FROM "public"."names"`,
+		`DELETE FROM "billing"."groups"`,
+	}, dialect{truncate: true}.TruncateReferenced(tables))
+}
+
+func TestDialectResetIdentity(t *testing.T) {
+	require.Equal(t, []string{
+		`SELECT setval(pg_get_serial_sequence('"public"."names"', 'id'), GREATEST(COALESCE(max("id"), 0) + 1, 1), false) FROM "public"."names"`,
+	}, dialect{}.ResetIdentity("public.names", "id"))
+	require.Equal(t, []string{
+		`SELECT setval(pg_get_serial_sequence('"public"."it''s"', 'id'), GREATEST(COALESCE(max("id"), 0) + 1, 1000), false) FROM "public"."it's"`,
+	}, dialect{identityStart: 1000}.ResetIdentity("public.it's", "id"))
+}
+
+func TestCopyable(t *testing.T) {
+	for _, tt := range []struct {
+		typ   string
+		value any
+		want  bool
+	}{
+		{typ: "integer", value: nil, want: true},
+		{typ: "bigint", value: int64(1), want: true},
+		{typ: "numeric", value: 1.5, want: true},
+		{typ: "integer", value: 1.5, want: false},
+		{typ: "boolean", value: true, want: true},
+		{typ: "character varying", value: "text", want: true},
+		{typ: "jsonb", value: `{"a": 1}`, want: true},
+		{typ: "bytea", value: []byte{1}, want: true},
+		{typ: "timestamp with time zone", value: time.Now(), want: true},
+		// postgres parses strings of other types itself, so they are
+		// inserted with INSERT.
+		{typ: "date", value: "2024-01-01", want: false},
+		{typ: "numeric", value: "1.5", want: false},
+		{typ: "ARRAY", value: "{1,2}", want: false},
+	} {
+		require.Equal(t, tt.want, copyable(tt.typ, tt.value), "%v: %#v", tt.typ, tt.value)
+	}
+}
@@ -2,9 +2,12 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"net/url"
 	"path/filepath"
 
+	"github.com/quenbyako/sqltest/dbenv"
 	"github.com/quenbyako/sqltest/dbenv/internal/util"
 	"github.com/testcontainers/testcontainers-go"
 )
@@ -47,35 +50,45 @@ func WithInitScripts(scripts ...string) testcontainers.CustomizeRequestOption {
 	}
 }
 
+// WithSetupSchema runs queries right after container start, and saves the
+// result as SetupSnapshot, so it can be restored or cloned later.
 func WithSetupSchema(queries []string) testcontainers.CustomizeRequestOption {
 	return func(req *testcontainers.GenericContainerRequest) {
+		if req.Labels == nil {
+			req.Labels = make(map[string]string)
+		}
+		req.Labels[setupSchemaLabel] = setupSchemaHash(queries)
+
 		req.LifecycleHooks = append(req.LifecycleHooks, testcontainers.ContainerLifecycleHooks{
 			PostStarts: []testcontainers.ContainerHook{
 				func(ctx context.Context, c testcontainers.Container) error {
-					connString, err := pgHostFromEnv(ctx, c)
+					env, err := pgEnvFromContainer(ctx, c)
 					if err != nil {
-						panic(err)
+						return err
 					}
 
-					conn, err := util.ConnectSQLContext(ctx, "pgx", connString)
+					conn, err := util.ConnectSQLContext(ctx, "pgx", env.connString(env.dbName))
 					if err != nil {
-						panic(err)
+						return err
 					}
 					defer conn.Close()
 
-					// поскольку мы проверяем все запросы, констрейнты нам не нужны, так что
-					// говорим что сессия в режиме реплики
-					if _, err = conn.Exec("SET session_replication_role = 'replica'"); err != nil {
-						panic(err)
+					if err := setupSchema(ctx, conn, queries, true); err != nil {
+						return err
 					}
 
-					for _, query := range queries {
-						if _, err := conn.Exec(query); err != nil {
-							panic(err)
-						}
+					// template database can't be copied while someone is
+					// connected to it.
+					conn.Close()
+					if err := snapshot(ctx, env, SetupSnapshot); err != nil {
+						return fmt.Errorf("making snapshot: %w", err)
 					}
 
-					req.Logger.Printf("🎉 Testing environment is setted up!")
+					logger := req.Logger
+					if logger == nil {
+						logger = testcontainers.Logger
+					}
+					logger.Printf("🎉 Testing environment is setted up!")
 
 					return nil
 				},
@@ -84,6 +97,119 @@ func WithSetupSchema(queries []string) testcontainers.CustomizeRequestOption {
 	}
 }
 
+// FlushOption configures Flush and Dump. It doesn't affect container itself,
+// so it's not a part of reused container identity.
+type FlushOption func(*flushConfig)
+
+type flushConfig struct {
+	schemas dbenv.Schemas
+	policy  dbenv.FlushPolicy
+	dialect dialect
+	report  func([]dbenv.TableStats)
+}
+
+func newFlushConfig(opts []testcontainers.ContainerCustomizer) flushConfig {
+	var cfg flushConfig
+	for _, opt := range opts {
+		if opt, ok := opt.(FlushOption); ok {
+			opt(&cfg)
+		}
+	}
+
+	return cfg
+}
+
+// flush replaces all data in the database and reports stats, if it's
+// requested.
+func (c flushConfig) flush(ctx context.Context, db *sql.DB, data map[string][]dbenv.TableRow) error {
+	stats, err := util.Flush(ctx, db, c.dialect, c.schemas, c.policy, data)
+	if err != nil {
+		return err
+	}
+
+	if c.report != nil {
+		c.report(stats)
+	}
+
+	return nil
+}
+
+func (FlushOption) Customize(*testcontainers.GenericContainerRequest) {}
+
+// WithSchemas selects tables, which are visible to Flush and Dump, and sets
+// default schema of unqualified table names. See dbenv.Schemas for details.
+func WithSchemas(schemas dbenv.Schemas) FlushOption {
+	return func(c *flushConfig) { c.schemas = schemas }
+}
+
+// WithFlushMode sets dbenv.FlushMode of Flush.
+// Foreign key rules are applied, when referenced tables are cleared.
+func WithFlushMode(mode dbenv.FlushMode) FlushOption {
+	return func(c *flushConfig) { c.policy.Mode = mode }
+}
+
+// WithProtected sets dbenv.FlushPolicy.Protected patterns of tables.
+func WithProtected(patterns ...string) FlushOption {
+	return func(c *flushConfig) { c.policy.Protected = append(c.policy.Protected, patterns...) }
+}
+
+// WithTruncate clears tables with TRUNCATE ... RESTART IDENTITY instead of
+// DELETE, which is faster for large tables and restarts all owned sequences,
+// but waits for every open transaction, which touched the tables. DELETE is
+// still used, when cleared tables are referenced by tables, which are not
+// cleared (e.g. with FlushListed mode), since TRUNCATE fails in this case.
+func WithTruncate() FlushOption {
+	return func(c *flushConfig) { c.dialect.truncate = true }
+}
+
+// WithIdentityStart sets minimal next value of identity and serial columns
+// after Flush, e.g. to distinguish rows inserted by tests from fixtures. By
+// default sequences continue right after the maximum value of the column.
+func WithIdentityStart(start int64) FlushOption {
+	return func(c *flushConfig) { c.dialect.identityStart = start }
+}
+
+// WithFlushReport calls f after every successful Flush with number of rows
+// and loading time of each table, e.g. to find out slow fixtures.
+func WithFlushReport(f func([]dbenv.TableStats)) FlushOption {
+	return func(c *flushConfig) { c.report = f }
+}
+
+// WithoutCopy disables COPY protocol, so all rows are inserted with multi-row
+// INSERT statements.
+func WithoutCopy() FlushOption {
+	return func(c *flushConfig) { c.dialect.noCopy = true }
+}
+
+// setupSchema runs queries on single connection. With replica, triggers and
+// foreign key checks are disabled for the session, which requires superuser.
+func setupSchema(ctx context.Context, db *sql.DB, queries []string, replica bool) error {
+	// session settings are applied only to single connection, so it must be
+	// pinned.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", dbenv.ErrConnection, err)
+	}
+	defer conn.Close()
+
+	// поскольку мы проверяем все запросы, констрейнты нам не нужны, так что
+	// говорим что сессия в режиме реплики
+	const replicaQuery = "SET session_replication_role = 'replica'"
+	if replica {
+		if _, err = conn.ExecContext(ctx, replicaQuery); err != nil {
+			return &dbenv.QueryError{Query: replicaQuery, Err: err}
+		}
+	}
+
+	for _, query := range queries {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("setting up schema: %w", &dbenv.QueryError{Query: query, Err: err})
+		}
+	}
+
+	return nil
+}
+
 /*
 // WithUsername sets the initial username to be created when the container starts
 // It is used in conjunction with WithPassword to set a user and its password.
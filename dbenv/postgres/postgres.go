@@ -2,7 +2,12 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
 	"net/url"
+	"testing"
 	"time"
 
 	"github.com/quenbyako/sqltest/dbenv"
@@ -19,9 +24,15 @@ const (
 // container represents the postgres container type used in the module
 type container struct {
 	testcontainers.Container
+	flush flushConfig
 }
 
-var _ dbenv.Container = (*container)(nil)
+var (
+	_ dbenv.Container     = (*container)(nil)
+	_ dbenv.Terminator    = (*container)(nil)
+	_ dbenv.ContextCloser = (*container)(nil)
+	_ dbenv.LogStreamer   = (*container)(nil)
+)
 
 // RunContainer creates an instance of the postgres container type
 func New(ctx context.Context, opts ...testcontainers.ContainerCustomizer) (_ dbenv.Container, err error) {
@@ -44,27 +55,46 @@ func New(ctx context.Context, opts ...testcontainers.ContainerCustomizer) (_ dbe
 	for _, opt := range opts {
 		opt.Customize(&genericContainerReq)
 	}
+	flush := newFlushConfig(opts)
 
 	var args url.Values
 	if argsRaw, ok := req.Env["POSTGRES_CONN_ARGS"]; ok {
 		if args, err = url.ParseQuery(argsRaw); err != nil {
-			panic(err)
+			return nil, fmt.Errorf("invalid connection args: %w", err)
 		}
 	}
 	withWaitSQL(req.Env["POSTGRES_DB"], args).Customize(&genericContainerReq)
 
+	if genericContainerReq.Reuse {
+		return newReused(ctx, genericContainerReq, flush)
+	}
+
 	c, err := testcontainers.GenericContainer(ctx, genericContainerReq)
 	if err != nil {
 		return nil, err
 	}
 
-	return &container{Container: c}, nil
+	return &container{Container: c, flush: flush}, nil
+}
+
+// NewT creates postgres container for the test, which is terminated on test
+// cleanup. See dbenv.NewT for details.
+func NewT(t testing.TB, opts ...testcontainers.ContainerCustomizer) dbenv.Container {
+	t.Helper()
+
+	return dbenv.NewT(t, New, opts...)
 }
 
 const defaultStopTimeout = 5 * time.Second
 
 func (c *container) Close() error {
-	return c.Container.Stop(context.Background(), ptr(defaultStopTimeout))
+	return c.CloseContext(context.Background())
+}
+
+// CloseContext stops the container, giving it defaultStopTimeout to stop
+// gracefully, or less, if ctx deadline is closer.
+func (c *container) CloseContext(ctx context.Context) error {
+	return util.StopContainer(ctx, c.Container, defaultStopTimeout)
 }
 
 func (c *container) ConnString(ctx context.Context) (string, error) {
@@ -72,71 +102,52 @@ func (c *container) ConnString(ctx context.Context) (string, error) {
 }
 
 func (c *container) Flush(ctx context.Context, data map[string][]dbenv.TableRow) error {
-	connString, err := pgHostFromEnv(ctx, c)
+	conn, err := c.connect(ctx)
 	if err != nil {
-		panic(err)
-	}
-
-	conn, err := util.ConnectSQLContext(ctx, "pgx", connString)
-	if err != nil {
-		panic(err)
+		return err
 	}
 	defer conn.Close()
 
-	tables, err := util.GetAllSchemaTables(ctx, conn)
-	if err != nil {
-		panic(err)
-	}
-	for name, schema := range tables {
-		// мы не можем здесь без шаманства с запросом, так как prepared запрос
-		// не поддерживает динамическое изменение названия таблицы. Это связано
-		// с тем, что prepare готовит план запроса под конкретную схему данных,
-		// поэтому любая динамическая схема невозможна впринципе.
-		_, err := conn.ExecContext(ctx, "DELETE FROM "+name)
-		if err != nil {
-			panic(err)
-		}
-		if values, ok := data[name]; ok {
-			if err := util.InsertData(ctx, conn, name, schema, values); err != nil {
-				panic(err)
-			}
-		}
-	}
-
-	return nil
+	return c.flush.flush(ctx, conn, data)
 }
 
 func (c *container) Dump(ctx context.Context) (map[string]dbenv.TableData, error) {
-	connString, err := pgHostFromEnv(ctx, c)
+	conn, err := c.connect(ctx)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	defer conn.Close()
 
-	conn, err := util.ConnectSQLContext(ctx, "pgx", connString)
+	return util.Dump(ctx, conn, c.flush.dialect, c.flush.schemas)
+}
+
+func (c *container) Query(ctx context.Context, query string, args ...any) ([]dbenv.TableRow, error) {
+	conn, err := c.connect(ctx)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer conn.Close()
 
-	tables, err := util.GetAllSchemaTables(ctx, conn)
+	return util.QueryRows(ctx, conn, c.flush.dialect, query, args...)
+}
+
+func (c *container) connect(ctx context.Context) (*sql.DB, error) {
+	connString, err := pgHostFromEnv(ctx, c)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	res := make(map[string]dbenv.TableData)
-	for name, schema := range tables {
-		data, err := util.DumpTable(ctx, conn, name, schema)
-		if err != nil {
-			panic(err)
-		}
+	return util.ConnectSQLContext(ctx, "pgx", connString)
+}
 
-		res[name] = dbenv.TableData{
-			Schema: schema,
-			Rows:   data,
-		}
+func (c *container) StreamLogs(ctx context.Context, w io.Writer) (stop func() error, err error) {
+	consumer := &util.LogConsumer{W: w}
+	c.Container.FollowOutput(consumer)
+	if err := c.Container.StartLogProducer(ctx); err != nil {
+		return nil, err
 	}
 
-	return res, nil
+	return func() error {
+		return errors.Join(c.Container.StopLogProducer(), consumer.Err())
+	}, nil
 }
-
-func ptr[T any](t T) *T { return &t }
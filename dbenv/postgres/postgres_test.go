@@ -0,0 +1,179 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/dbenv/postgres"
+	"github.com/quenbyako/sqltest/tabsync"
+)
+
+func TestFlushResetsIdentity(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name   string
+		opts   []testcontainers.ContainerCustomizer
+		wantID int64
+	}{
+		{name: "Max", wantID: 11},
+		{name: "Truncate", opts: []testcontainers.ContainerCustomizer{postgres.WithTruncate()}, wantID: 11},
+		{name: "Start", opts: []testcontainers.ContainerCustomizer{postgres.WithIdentityStart(1000)}, wantID: 1000},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := postgres.NewT(t, append(tt.opts, postgres.WithSetupSchema([]string{
+				"CREATE TABLE serials (id serial PRIMARY KEY, name text)",
+				"CREATE TABLE identities (id integer GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, name text)",
+			}))...)
+
+			for i := 0; i < 2; i++ { // second flush must give the same ids
+				require.NoError(t, tabsync.FlushRaw(c, map[string][]map[string]driver.Value{
+					"serials":    {{"id": 10, "name": "fixture"}},
+					"identities": {{"id": 10, "name": "fixture"}},
+				}))
+
+				for _, table := range []string{"serials", "identities"} {
+					rows, err := c.Query(ctx, "INSERT INTO "+table+" (name) VALUES ('test') RETURNING id")
+					require.NoError(t, err)
+					require.Equal(t, tt.wantID, rows[0]["id"], table)
+				}
+			}
+		})
+	}
+}
+
+func TestFlushCopy(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name       string
+		opts       []testcontainers.ContainerCustomizer
+		wantCopied []int
+	}{
+		{name: "Copy", wantCopied: []int{2, 0}},
+		{name: "WithoutCopy", opts: []testcontainers.ContainerCustomizer{postgres.WithoutCopy()}, wantCopied: []int{0, 0}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats []dbenv.TableStats
+			c := postgres.NewT(t, append(tt.opts,
+				postgres.WithFlushReport(func(s []dbenv.TableStats) { stats = s }),
+				postgres.WithSetupSchema([]string{
+					"CREATE TABLE groups (id integer PRIMARY KEY, name text, created timestamptz)",
+					"CREATE TABLE names (id integer PRIMARY KEY, group_id integer REFERENCES groups (id), birthday date)",
+				}),
+			)...)
+
+			require.NoError(t, tabsync.FlushRaw(c, map[string][]map[string]driver.Value{
+				"groups": {
+					{"id": 1, "name": "Admins", "created": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+					{"id": 2, "name": "Users", "created": nil},
+				},
+				// dates as strings can't be copied
+				"names": {{"id": 1, "group_id": 1, "birthday": "2000-01-01"}},
+			}))
+
+			require.Len(t, stats, 2)
+			for i, table := range []string{"groups", "names"} {
+				require.Equal(t, table, stats[i].Table)
+				require.Equal(t, tt.wantCopied[i], stats[i].Copied)
+			}
+			require.Equal(t, 2, stats[0].Rows)
+
+			rows, err := c.Query(ctx, "SELECT name FROM groups ORDER BY id")
+			require.NoError(t, err)
+			require.Equal(t, []dbenv.TableRow{{"name": "Admins"}, {"name": "Users"}}, rows)
+		})
+	}
+}
+
+func TestFlushListedReferenced(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	c := postgres.NewT(t, postgres.WithTruncate(), postgres.WithFlushMode(dbenv.FlushListed), postgres.WithSetupSchema([]string{
+		"CREATE TABLE groups (id serial PRIMARY KEY, name text)",
+		"CREATE TABLE names (id serial PRIMARY KEY, group_id integer REFERENCES groups ON DELETE CASCADE)",
+	}))
+
+	require.NoError(t, tabsync.FlushRaw(c, map[string][]map[string]driver.Value{
+		"groups": {{"id": 1, "name": "Admins"}},
+		"names":  {{"id": 1, "group_id": 1}},
+	}))
+
+	// names is not cleared, so groups can't be truncated, and it's cleared
+	// with DELETE instead.
+	require.NoError(t, tabsync.FlushRaw(c, map[string][]map[string]driver.Value{
+		"groups": {{"id": 10, "name": "Users"}},
+	}))
+
+	rows, err := c.Query(ctx, "SELECT count(*) AS count FROM names")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), rows[0]["count"])
+
+	rows, err = c.Query(ctx, "INSERT INTO groups (name) VALUES ('test') RETURNING id")
+	require.NoError(t, err)
+	require.Equal(t, int64(11), rows[0]["id"])
+}
+
+func TestAttachNotSuperuser(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	c := postgres.NewT(t)
+	_, err := c.Query(ctx, "CREATE ROLE app LOGIN PASSWORD 'app' CREATEDB")
+	require.NoError(t, err)
+
+	connString, err := c.ConnString(ctx)
+	require.NoError(t, err)
+	dsn, err := url.Parse(connString)
+	require.NoError(t, err)
+	dsn.User = url.UserPassword("app", "app")
+
+	a, err := postgres.Attach(ctx, dsn.String(), postgres.AttachCreateDatabase(), postgres.AttachSetupSchema([]string{
+		"CREATE TABLE groups (id serial PRIMARY KEY, name text)",
+	}))
+	require.NoError(t, err)
+	defer a.Close()
+
+	require.NoError(t, tabsync.FlushRaw(a, map[string][]map[string]driver.Value{
+		"groups": {{"id": 1, "name": "Admins"}},
+	}))
+}
+
+func TestDumpArrayTypes(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	c := postgres.NewT(t, postgres.WithSetupSchema([]string{
+		"CREATE TABLE tags (id serial PRIMARY KEY, ids integer[], names text[])",
+		"INSERT INTO tags (ids, names) VALUES ('{1,2,NULL}', '{\"a,b\",c}')",
+	}))
+
+	tables, err := c.Dump(ctx)
+	require.NoError(t, err)
+
+	for _, column := range tables["tags"].Schema.Types {
+		if column.Name == "id" {
+			continue
+		}
+		require.Contains(t, []string{"_int4", "_text"}, column.Typ, column.Name)
+		_, ok := tabsync.LookupType(column.Typ)
+		require.True(t, ok, column.Typ)
+	}
+
+	require.NoError(t, tabsync.ValidateResultCSV(c, "SELECT ids, names FROM tags", strings.NewReader(
+		"ids:_int4,names:_text\n"+
+			`"{1,2,NULL}","{""a,b"",c}"`+"\n",
+	)))
+}
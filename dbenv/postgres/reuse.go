@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/quenbyako/ext/maps"
+	"github.com/quenbyako/ext/slices"
+	"github.com/testcontainers/testcontainers-go"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+const (
+	reusePrefix = "sqltest-postgres-"
+	// WithSetupSchema queries are hidden in lifecycle hook, so their hash is
+	// stored in container label to make it part of container identity.
+	setupSchemaLabel = "sqltest.setup-schema"
+
+	lockRetryDelay = 100 * time.Millisecond
+)
+
+// WithReuse shares single container between all tests, packages and test
+// binaries, which are using the same configuration: image, environment,
+// command, files and setup schema. Container is started once and never
+// stopped by tests, and every New call gets its own database, cloned from
+// SetupSnapshot, which is dropped on Close. Creation is guarded by file lock,
+// so concurrent test binaries don't race with each other.
+func WithReuse() testcontainers.CustomizeRequestOption {
+	return func(req *testcontainers.GenericContainerRequest) {
+		req.Reuse = true
+	}
+}
+
+func newReused(ctx context.Context, req testcontainers.GenericContainerRequest, flush flushConfig) (dbenv.Container, error) {
+	hash, err := requestHash(req.ContainerRequest)
+	if err != nil {
+		return nil, fmt.Errorf("can't identify container: %w", err)
+	}
+	req.Name = reusePrefix + hash
+
+	lock := flock.New(filepath.Join(os.TempDir(), req.Name+".lock"))
+	if _, err := lock.TryLockContext(ctx, lockRetryDelay); err != nil {
+		return nil, fmt.Errorf("can't lock container %v: %w", req.Name, err)
+	}
+	defer lock.Unlock()
+
+	c, err := testcontainers.GenericContainer(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	pg := &container{Container: c, flush: flush}
+	if err := pg.ensureSnapshot(ctx, SetupSnapshot); err != nil {
+		return nil, err
+	}
+
+	return pg.Clone(ctx, SetupSnapshot)
+}
+
+// ensureSnapshot makes snapshot, only if it doesn't exist yet.
+func (c *container) ensureSnapshot(ctx context.Context, name string) error {
+	env, err := pgEnvFromContainer(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	err = maintain(ctx, env, func(conn *sql.DB) error { return requireDatabase(ctx, conn, snapshotPrefix+name) })
+	if errors.Is(err, dbenv.ErrSnapshotNotFound) {
+		return snapshot(ctx, env, name)
+	}
+
+	return err
+}
+
+// requestHash identifies container by everything, which affects its state.
+func requestHash(req testcontainers.ContainerRequest) (string, error) {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "image=%v\n", req.Image)
+	fmt.Fprintf(h, "cmd=%q\n", req.Cmd)
+	fmt.Fprintf(h, "ports=%q\n", req.ExposedPorts)
+	for _, k := range slices.Sort(maps.Keys(req.Env)) {
+		fmt.Fprintf(h, "env %v=%v\n", k, req.Env[k])
+	}
+	for _, k := range slices.Sort(maps.Keys(req.Labels)) {
+		fmt.Fprintf(h, "label %v=%v\n", k, req.Labels[k])
+	}
+	for _, f := range req.Files {
+		fmt.Fprintf(h, "file %v %o\n", f.ContainerFilePath, f.FileMode)
+		if err := hashFile(h, f); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// hashFile writes content of the file, or of all files of the directory, to
+// w. Files without host path can't be identified, so they are rejected.
+func hashFile(w io.Writer, f testcontainers.ContainerFile) error {
+	if f.HostFilePath == "" {
+		return fmt.Errorf("file %v: host path is required for reused containers", f.ContainerFilePath)
+	}
+
+	return filepath.WalkDir(f.HostFilePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		rel, err := filepath.Rel(f.HostFilePath, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "content %v\n", filepath.ToSlash(rel))
+		_, err = io.Copy(w, file)
+		return err
+	})
+}
+
+func setupSchemaHash(queries []string) string {
+	h := sha256.Sum256([]byte(strings.Join(queries, "\x00")))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+func TestRequestHash(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "init.sql")
+	require.NoError(t, os.WriteFile(script, []byte("CREATE TABLE a (id int)"), 0o644))
+
+	hash := func(opts ...testcontainers.CustomizeRequestOption) string {
+		req := testcontainers.GenericContainerRequest{
+			ContainerRequest: testcontainers.ContainerRequest{
+				Image: defaultPostgresImage,
+				Env:   map[string]string{"POSTGRES_USER": defaultUser, "POSTGRES_DB": defaultUser},
+			},
+		}
+		for _, opt := range opts {
+			opt.Customize(&req)
+		}
+
+		h, err := requestHash(req.ContainerRequest)
+		require.NoError(t, err)
+		return h
+	}
+
+	base := hash(WithInitScripts(script), WithSetupSchema([]string{"CREATE TABLE b (id int)"}))
+	require.Equal(t, base, hash(WithInitScripts(script), WithSetupSchema([]string{"CREATE TABLE b (id int)"})))
+	require.NotEqual(t, base, hash(WithInitScripts(script), WithSetupSchema([]string{"CREATE TABLE c (id int)"})))
+	require.NotEqual(t, base, hash(WithSetupSchema([]string{"CREATE TABLE b (id int)"})))
+
+	require.NoError(t, os.WriteFile(script, []byte("CREATE TABLE d (id int)"), 0o644))
+	require.NotEqual(t, base, hash(WithInitScripts(script), WithSetupSchema([]string{"CREATE TABLE b (id int)"})),
+		"changed init script must change the hash")
+
+	// directories are hashed with all their files.
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "init.sql"), []byte("CREATE TABLE a (id int)"), 0o644))
+	withDir := func(req *testcontainers.GenericContainerRequest) {
+		req.Files = append(req.Files, testcontainers.ContainerFile{HostFilePath: dir, ContainerFilePath: "/docker-entrypoint-initdb.d"})
+	}
+	dirHash := hash(withDir)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "init.sql"), []byte("CREATE TABLE b (id int)"), 0o644))
+	require.NotEqual(t, dirHash, hash(withDir), "changed file of directory must change the hash")
+
+	_, err := requestHash(testcontainers.ContainerRequest{Files: []testcontainers.ContainerFile{{ContainerFilePath: "/init.sql"}}})
+	require.EqualError(t, err, "file /init.sql: host path is required for reused containers")
+}
@@ -0,0 +1,404 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/quenbyako/ext/slices"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+const allTablesQuery = `
+SELECT
+	tables.table_schema::text,
+	tables.table_name::text,
+	string_agg(columns.column_name::text, ', ' ORDER BY columns.ordinal_position) AS key_columns
+FROM information_schema.tables AS tables
+LEFT JOIN information_schema.table_constraints constraints ON
+	constraints.table_schema = tables.table_schema AND
+	constraints.table_name = tables.table_name AND
+	constraints.constraint_type = 'PRIMARY KEY'
+LEFT JOIN information_schema.key_column_usage columns ON
+	columns.constraint_schema = constraints.constraint_schema AND
+	columns.constraint_name = constraints.constraint_name AND
+	columns.table_schema = constraints.table_schema AND
+	columns.table_name = constraints.table_name
+WHERE
+	tables.table_schema NOT IN ('pg_catalog', 'information_schema') AND
+	tables.table_type = 'BASE TABLE'
+GROUP BY
+	tables.table_schema,
+	tables.table_name
+ORDER BY
+	tables.table_schema,
+	tables.table_name
+`
+
+type tableInfo struct {
+	Schema      string   `db:"table_schema"`
+	Name        string   `db:"table_name"`
+	PrimaryKeys []string `db:"primary_keys"`
+}
+
+const tableColumnsQuery = `
+SELECT
+	column_name::text,
+	data_type::text,
+	udt_name::text,
+	is_nullable = 'YES',
+	COALESCE(column_default, '')::text,
+	is_identity = 'YES' OR COALESCE(column_default, '') LIKE 'nextval(%'
+FROM information_schema.columns WHERE table_schema = $1 AND table_name = $2
+ORDER BY ordinal_position
+`
+
+type tableColumnsRow struct {
+	ColumnName string `db:"column_name"`
+	Type       string `db:"data_type"`
+	UDTName    string `db:"udt_name"`
+	Nullable   bool   `db:"is_nullable"`
+	Default    string `db:"column_default"`
+	Identity   bool   `db:"is_identity"` // identity or serial column
+}
+
+// typeName returns name of the column type. Enums and arrays are reported by
+// their internal names, e.g. "mood" or "_int4", since data_type is the same
+// for all of them.
+func (r tableColumnsRow) typeName() string {
+	switch r.Type {
+	case "USER-DEFINED", "ARRAY":
+		return r.UDTName
+	default:
+		return r.Type
+	}
+}
+
+// there is no way to get composite foreign keys with proper column order from
+// information_schema, so pg_catalog is used here.
+const foreignKeysQuery = `
+SELECT
+	con.conname::text,
+	src_ns.nspname::text AS table_schema,
+	src.relname::text AS table_name,
+	dst_ns.nspname::text AS ref_table_schema,
+	dst.relname::text AS ref_table_name,
+	(
+		SELECT string_agg(a.attname::text, ', ' ORDER BY k.n)
+		FROM unnest(con.conkey) WITH ORDINALITY AS k(attnum, n)
+		JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+	) AS columns,
+	(
+		SELECT string_agg(a.attname::text, ', ' ORDER BY k.n)
+		FROM unnest(con.confkey) WITH ORDINALITY AS k(attnum, n)
+		JOIN pg_attribute a ON a.attrelid = con.confrelid AND a.attnum = k.attnum
+	) AS ref_columns,
+	CASE con.confdeltype
+		WHEN 'a' THEN 'NO ACTION'
+		WHEN 'r' THEN 'RESTRICT'
+		WHEN 'c' THEN 'CASCADE'
+		WHEN 'n' THEN 'SET NULL'
+		WHEN 'd' THEN 'SET DEFAULT'
+	END AS on_delete,
+	CASE con.confupdtype
+		WHEN 'a' THEN 'NO ACTION'
+		WHEN 'r' THEN 'RESTRICT'
+		WHEN 'c' THEN 'CASCADE'
+		WHEN 'n' THEN 'SET NULL'
+		WHEN 'd' THEN 'SET DEFAULT'
+	END AS on_update
+FROM pg_constraint con
+JOIN pg_class src ON src.oid = con.conrelid
+JOIN pg_class dst ON dst.oid = con.confrelid
+JOIN pg_namespace src_ns ON src_ns.oid = src.relnamespace
+JOIN pg_namespace dst_ns ON dst_ns.oid = dst.relnamespace
+WHERE
+	con.contype = 'f' AND
+	src_ns.nspname NOT IN ('pg_catalog', 'information_schema')
+ORDER BY
+	src_ns.nspname,
+	src.relname,
+	con.conname
+`
+
+type foreignKeyRow struct {
+	Name           string `db:"conname"`
+	TableSchema    string `db:"table_schema"`
+	TableName      string `db:"table_name"`
+	RefTableSchema string `db:"ref_table_schema"`
+	RefTableName   string `db:"ref_table_name"`
+	Columns        string `db:"columns"`
+	RefColumns     string `db:"ref_columns"`
+	OnDelete       string `db:"on_delete"`
+	OnUpdate       string `db:"on_update"`
+}
+
+// unique indexes cover unique constraints too. Partial and expression indexes
+// are skipped, since they can't be described as list of columns.
+const uniquesQuery = `
+SELECT
+	ns.nspname::text,
+	tbl.relname::text,
+	idx.relname::text,
+	string_agg(a.attname::text, ', ' ORDER BY k.n)
+FROM pg_index i
+JOIN pg_class idx ON idx.oid = i.indexrelid
+JOIN pg_class tbl ON tbl.oid = i.indrelid
+JOIN pg_namespace ns ON ns.oid = tbl.relnamespace
+CROSS JOIN LATERAL unnest(i.indkey::int2[]) WITH ORDINALITY AS k(attnum, n)
+JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+WHERE
+	i.indisunique AND
+	NOT i.indisprimary AND
+	i.indpred IS NULL AND
+	NOT (0 = ANY (i.indkey::int2[])) AND
+	ns.nspname NOT IN ('pg_catalog', 'information_schema')
+GROUP BY
+	ns.nspname,
+	tbl.relname,
+	idx.relname
+ORDER BY
+	ns.nspname,
+	tbl.relname,
+	idx.relname
+`
+
+const checksQuery = `
+SELECT
+	ns.nspname::text,
+	tbl.relname::text,
+	con.conname::text,
+	pg_get_expr(con.conbin, con.conrelid)::text
+FROM pg_constraint con
+JOIN pg_class tbl ON tbl.oid = con.conrelid
+JOIN pg_namespace ns ON ns.oid = tbl.relnamespace
+WHERE
+	con.contype = 'c' AND
+	ns.nspname NOT IN ('pg_catalog', 'information_schema')
+ORDER BY
+	ns.nspname,
+	tbl.relname,
+	con.conname
+`
+
+// getAllSchemaTables returns tables of all schemas, keyed by qualified name,
+// e.g. "public.users".
+func getAllSchemaTables(ctx context.Context, tx dbenv.Queryer) (map[string]dbenv.TableSchema, error) {
+	tables, err := getTables(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	foreignKeys, err := getForeignKeys(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	uniques, err := getUniques(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	checks, err := getChecks(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]dbenv.TableSchema)
+	for _, table := range tables {
+		returns, err := getTableColumns(ctx, tx, table.Schema, table.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		name := table.Schema + "." + table.Name
+		res[name] = dbenv.TableSchema{
+			PrimaryKeys: table.PrimaryKeys,
+			Types: slices.Remap(returns, func(r tableColumnsRow) dbenv.ColumnType {
+				return dbenv.ColumnType{
+					Name:     r.ColumnName,
+					Typ:      r.typeName(),
+					Nullable: r.Nullable,
+					Default:  r.Default,
+					Identity: r.Identity,
+				}
+			}),
+			ForeignKeys: foreignKeys[name],
+			Uniques:     uniques[name],
+			Checks:      checks[name],
+		}
+	}
+
+	return res, nil
+}
+
+func introspectionErr(query string, err error) error {
+	return fmt.Errorf("%w: %w", dbenv.ErrIntrospection, &dbenv.QueryError{Query: query, Err: err})
+}
+
+func getTables(ctx context.Context, tx dbenv.Queryer) ([]tableInfo, error) {
+	rows, err := tx.QueryContext(ctx, allTablesQuery)
+	if err != nil {
+		return nil, introspectionErr(allTablesQuery, err)
+	}
+	defer rows.Close()
+
+	tables := []tableInfo{}
+	for rows.Next() {
+		var i tableInfo
+		var pkeys sql.NullString
+		if err := rows.Scan(&i.Schema, &i.Name, &pkeys); err != nil {
+			return nil, introspectionErr(allTablesQuery, err)
+		}
+		i.PrimaryKeys = splitColumns(pkeys.String)
+		tables = append(tables, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, introspectionErr(allTablesQuery, err)
+	}
+
+	return tables, nil
+}
+
+func getTableColumns(ctx context.Context, tx dbenv.Queryer, schema, tableName string) ([]tableColumnsRow, error) {
+	rows, err := tx.QueryContext(ctx, tableColumnsQuery, schema, tableName)
+	if err != nil {
+		return nil, introspectionErr(tableColumnsQuery, err)
+	}
+	defer rows.Close()
+
+	returns := []tableColumnsRow{}
+	for rows.Next() {
+		var i tableColumnsRow
+		if err := rows.Scan(&i.ColumnName, &i.Type, &i.UDTName, &i.Nullable, &i.Default, &i.Identity); err != nil {
+			return nil, introspectionErr(tableColumnsQuery, err)
+		}
+		returns = append(returns, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, introspectionErr(tableColumnsQuery, err)
+	}
+
+	return returns, nil
+}
+
+func getForeignKeys(ctx context.Context, tx dbenv.Queryer) (map[string][]dbenv.ForeignKey, error) {
+	rows, err := tx.QueryContext(ctx, foreignKeysQuery)
+	if err != nil {
+		return nil, introspectionErr(foreignKeysQuery, err)
+	}
+	defer rows.Close()
+
+	res := make(map[string][]dbenv.ForeignKey)
+	for rows.Next() {
+		var i foreignKeyRow
+		if err := rows.Scan(&i.Name, &i.TableSchema, &i.TableName, &i.RefTableSchema, &i.RefTableName, &i.Columns, &i.RefColumns, &i.OnDelete, &i.OnUpdate); err != nil {
+			return nil, introspectionErr(foreignKeysQuery, err)
+		}
+
+		name := i.TableSchema + "." + i.TableName
+		res[name] = append(res[name], dbenv.ForeignKey{
+			Name:       i.Name,
+			Columns:    splitColumns(i.Columns),
+			RefTable:   i.RefTableSchema + "." + i.RefTableName,
+			RefColumns: splitColumns(i.RefColumns),
+			OnDelete:   i.OnDelete,
+			OnUpdate:   i.OnUpdate,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, introspectionErr(foreignKeysQuery, err)
+	}
+
+	return res, nil
+}
+
+func getUniques(ctx context.Context, tx dbenv.Queryer) (map[string][]dbenv.Unique, error) {
+	rows, err := tx.QueryContext(ctx, uniquesQuery)
+	if err != nil {
+		return nil, introspectionErr(uniquesQuery, err)
+	}
+	defer rows.Close()
+
+	res := make(map[string][]dbenv.Unique)
+	for rows.Next() {
+		var schema, table, name, columns string
+		if err := rows.Scan(&schema, &table, &name, &columns); err != nil {
+			return nil, introspectionErr(uniquesQuery, err)
+		}
+
+		res[schema+"."+table] = append(res[schema+"."+table], dbenv.Unique{Name: name, Columns: splitColumns(columns)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, introspectionErr(uniquesQuery, err)
+	}
+
+	return res, nil
+}
+
+func getChecks(ctx context.Context, tx dbenv.Queryer) (map[string][]dbenv.Check, error) {
+	rows, err := tx.QueryContext(ctx, checksQuery)
+	if err != nil {
+		return nil, introspectionErr(checksQuery, err)
+	}
+	defer rows.Close()
+
+	res := make(map[string][]dbenv.Check)
+	for rows.Next() {
+		var schema, table, name, expr string
+		if err := rows.Scan(&schema, &table, &name, &expr); err != nil {
+			return nil, introspectionErr(checksQuery, err)
+		}
+
+		res[schema+"."+table] = append(res[schema+"."+table], dbenv.Check{Name: name, Expr: expr})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, introspectionErr(checksQuery, err)
+	}
+
+	return res, nil
+}
+
+func splitColumns(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, ", ")
+}
+
+func currentSchema(ctx context.Context, tx dbenv.Queryer) (string, error) {
+	const query = "SELECT current_schema()::text"
+
+	var res sql.NullString
+	if err := scanOne(ctx, tx, query, &res); err != nil {
+		return "", err
+	} else if !res.Valid {
+		// search_path doesn't contain any existing schema
+		return "public", nil
+	}
+
+	return res.String, nil
+}
+
+func scanOne(ctx context.Context, tx dbenv.Queryer, query string, dest ...any) error {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return introspectionErr(query, err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return introspectionErr(query, err)
+		}
+		return introspectionErr(query, sql.ErrNoRows)
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return introspectionErr(query, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableColumnsTypeName(t *testing.T) {
+	for _, tt := range []struct {
+		row  tableColumnsRow
+		want string
+	}{
+		{row: tableColumnsRow{Type: "integer", UDTName: "int4"}, want: "integer"},
+		{row: tableColumnsRow{Type: "ARRAY", UDTName: "_int4"}, want: "_int4"},
+		{row: tableColumnsRow{Type: "USER-DEFINED", UDTName: "mood"}, want: "mood"},
+	} {
+		t.Run(tt.row.Type, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.row.typeName())
+		})
+	}
+}
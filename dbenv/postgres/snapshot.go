@@ -0,0 +1,188 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/dbenv/internal/util"
+)
+
+// SetupSnapshot is a snapshot, which is made right after WithSetupSchema
+// queries are applied.
+const SetupSnapshot = "setup"
+
+const (
+	// postgres doesn't allow to copy or drop database with active connections,
+	// so all CREATE DATABASE and DROP DATABASE queries are run from here.
+	maintenanceDB = "template1"
+
+	snapshotPrefix = "sqltest_snapshot_"
+	clonePrefix    = "sqltest_clone_"
+)
+
+var _ dbenv.Snapshotter = (*container)(nil)
+
+func (c *container) Snapshot(ctx context.Context, name string) error {
+	env, err := pgEnvFromContainer(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	return snapshot(ctx, env, name)
+}
+
+func (c *container) Restore(ctx context.Context, name string) error {
+	env, err := pgEnvFromContainer(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	return maintain(ctx, env, func(conn *sql.DB) error {
+		if err := requireDatabase(ctx, conn, snapshotPrefix+name); err != nil {
+			return fmt.Errorf("snapshot %#v: %w", name, err)
+		}
+
+		return execAll(ctx, conn,
+			"DROP DATABASE IF EXISTS "+quoteIdent(env.dbName)+" WITH (FORCE)",
+			"CREATE DATABASE "+quoteIdent(env.dbName)+" TEMPLATE "+quoteIdent(snapshotPrefix+name),
+		)
+	})
+}
+
+func (c *container) Clone(ctx context.Context, snapshot string) (dbenv.Container, error) {
+	env, err := pgEnvFromContainer(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	name := clonePrefix + strings.ReplaceAll(uuid.NewString(), "-", "")
+	err = maintain(ctx, env, func(conn *sql.DB) error {
+		if err := requireDatabase(ctx, conn, snapshotPrefix+snapshot); err != nil {
+			return fmt.Errorf("snapshot %#v: %w", snapshot, err)
+		}
+
+		return execAll(ctx, conn, "CREATE DATABASE "+quoteIdent(name)+" TEMPLATE "+quoteIdent(snapshotPrefix+snapshot))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &clone{env: env, name: name, flush: c.flush}, nil
+}
+
+// snapshot copies main database of the container to the template one.
+func snapshot(ctx context.Context, env pgEnv, name string) error {
+	return maintain(ctx, env, func(conn *sql.DB) error {
+		const terminateQuery = "SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1 AND pid <> pg_backend_pid()"
+		if _, err := conn.ExecContext(ctx, terminateQuery, env.dbName); err != nil {
+			return &dbenv.QueryError{Query: terminateQuery, Err: err}
+		}
+
+		return execAll(ctx, conn,
+			"DROP DATABASE IF EXISTS "+quoteIdent(snapshotPrefix+name)+" WITH (FORCE)",
+			"CREATE DATABASE "+quoteIdent(snapshotPrefix+name)+" TEMPLATE "+quoteIdent(env.dbName),
+		)
+	})
+}
+
+func maintain(ctx context.Context, env pgEnv, f func(conn *sql.DB) error) error {
+	conn, err := util.ConnectSQLContext(ctx, "pgx", env.connString(maintenanceDB))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return f(conn)
+}
+
+func requireDatabase(ctx context.Context, conn *sql.DB, name string) error {
+	const query = "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)"
+
+	var exists bool
+	if err := conn.QueryRowContext(ctx, query, name).Scan(&exists); err != nil {
+		return &dbenv.QueryError{Query: query, Err: err}
+	} else if !exists {
+		return dbenv.ErrSnapshotNotFound
+	}
+
+	return nil
+}
+
+func execAll(ctx context.Context, conn *sql.DB, queries ...string) error {
+	for _, query := range queries {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return &dbenv.QueryError{Query: query, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// clone is a separate database inside postgres container, created from
+// snapshot.
+type clone struct {
+	env   pgEnv
+	name  string
+	flush flushConfig
+}
+
+var (
+	_ dbenv.Container     = (*clone)(nil)
+	_ dbenv.ContextCloser = (*clone)(nil)
+)
+
+func (c *clone) ConnString(context.Context) (string, error) { return c.env.connString(c.name), nil }
+
+// Close drops the database.
+func (c *clone) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStopTimeout)
+	defer cancel()
+
+	return c.CloseContext(ctx)
+}
+
+// CloseContext is Close limited by ctx.
+func (c *clone) CloseContext(ctx context.Context) error {
+	return maintain(ctx, c.env, func(conn *sql.DB) error {
+		return execAll(ctx, conn, "DROP DATABASE IF EXISTS "+quoteIdent(c.name)+" WITH (FORCE)")
+	})
+}
+
+func (c *clone) Flush(ctx context.Context, data map[string][]dbenv.TableRow) error {
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return c.flush.flush(ctx, conn, data)
+}
+
+func (c *clone) Dump(ctx context.Context) (map[string]dbenv.TableData, error) {
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return util.Dump(ctx, conn, c.flush.dialect, c.flush.schemas)
+}
+
+func (c *clone) Query(ctx context.Context, query string, args ...any) ([]dbenv.TableRow, error) {
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return util.QueryRows(ctx, conn, c.flush.dialect, query, args...)
+}
+
+func (c *clone) connect(ctx context.Context) (*sql.DB, error) {
+	return util.ConnectSQLContext(ctx, "pgx", c.env.connString(c.name))
+}
@@ -4,32 +4,73 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"strings"
 
 	"github.com/docker/go-connections/nat"
+	"github.com/quenbyako/sqltest/dbenv"
 	"github.com/quenbyako/sqltest/dbenv/internal/util"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
 )
 
-func pgHostFromEnv(ctx context.Context, c testcontainers.Container) (connStr string, err error) {
+// pgEnv is everything required to connect to any database of the container.
+type pgEnv struct {
+	user, password string
+	host           string
+	port           int
+	dbName         string // database, created on startup
+	args           url.Values
+}
+
+func (e pgEnv) connString(dbName string) string {
+	return pgHost(e.user, e.password, e.host, e.port, dbName, e.args)
+}
+
+func pgEnvFromContainer(ctx context.Context, c testcontainers.Container) (pgEnv, error) {
 	env, err := util.GetContainerEnv(ctx, c)
 	if err != nil {
-		panic(err)
+		return pgEnv{}, fmt.Errorf("%w: %w", dbenv.ErrConnection, err)
 	}
 
 	host, port, err := util.ContainerHostPort(ctx, c, "5432/tcp")
 	if err != nil {
-		panic(err)
+		return pgEnv{}, fmt.Errorf("%w: %w", dbenv.ErrConnection, err)
 	}
 
 	var args url.Values
 	if argsRaw, ok := env["POSTGRES_CONN_ARGS"]; ok {
 		if args, err = url.ParseQuery(argsRaw); err != nil {
-			panic(err)
+			return pgEnv{}, fmt.Errorf("%w: invalid connection args: %w", dbenv.ErrConnection, err)
 		}
 	}
 
-	return pgHost(env["POSTGRES_USER"], env["POSTGRES_PASSWORD"], host, port, env["POSTGRES_DB"], args), nil
+	return pgEnv{
+		user:     env["POSTGRES_USER"],
+		password: env["POSTGRES_PASSWORD"],
+		host:     host,
+		port:     port,
+		dbName:   env["POSTGRES_DB"],
+		args:     args,
+	}, nil
+}
+
+func pgHostFromEnv(ctx context.Context, c testcontainers.Container) (connStr string, err error) {
+	env, err := pgEnvFromContainer(ctx, c)
+	if err != nil {
+		return "", err
+	}
+
+	return env.connString(env.dbName), nil
+}
+
+// quoteIdent quotes identifier, so it can be used in queries as is.
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes string constant, so it can be used in queries as is.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
 }
 
 func pgHost(user, password, host string, port int, dbName string, opts url.Values) string {
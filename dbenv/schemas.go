@@ -0,0 +1,61 @@
+package dbenv
+
+import (
+	"path"
+	"strings"
+)
+
+// Schemas selects tables, which are visible to Flush and Dump, and defines,
+// how they are named. Tables of the default schema are named without
+// qualifier, e.g. "users", and tables of other schemas are qualified, e.g.
+// "billing.invoices".
+type Schemas struct {
+	// Default is the schema of unqualified names, the same way as
+	// search_path works in postgres. Empty value means current schema of the
+	// connection.
+	Default string
+	// Include and Exclude are path.Match patterns of qualified table names,
+	// e.g. "billing.*" or "*.schema_migrations". Table is visible, if it
+	// matches any of Include patterns (or Include is empty) and none of
+	// Exclude patterns.
+	Include []string
+	Exclude []string
+}
+
+// Visible reports whether table of the schema passes Include and Exclude
+// filters.
+func (s Schemas) Visible(schema, table string) bool {
+	name := schema + "." + table
+
+	return (len(s.Include) == 0 || matchAny(s.Include, name)) && !matchAny(s.Exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Name returns name of the table, which is used in TableData and Flush, i.e.
+// without schema, if it's the default one.
+func (s Schemas) Name(schema, table string) string {
+	if schema == s.Default {
+		return table
+	}
+
+	return schema + "." + table
+}
+
+// Qualify splits name of the table into schema and table. Unqualified names
+// belong to the default schema.
+func (s Schemas) Qualify(name string) (schema, table string) {
+	if schema, table, ok := strings.Cut(name, "."); ok {
+		return schema, table
+	}
+
+	return s.Default, name
+}
@@ -0,0 +1,30 @@
+package dbenv_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+func TestSchemas(t *testing.T) {
+	s := dbenv.Schemas{
+		Default: "public",
+		Include: []string{"public.*", "billing.*"},
+		Exclude: []string{"*.schema_migrations"},
+	}
+
+	require.True(t, s.Visible("public", "users"))
+	require.True(t, s.Visible("billing", "invoices"))
+	require.False(t, s.Visible("audit", "events"))
+	require.False(t, s.Visible("public", "schema_migrations"))
+
+	require.Equal(t, "users", s.Name("public", "users"))
+	require.Equal(t, "billing.invoices", s.Name("billing", "invoices"))
+
+	schema, table := s.Qualify("users")
+	require.Equal(t, []string{"public", "users"}, []string{schema, table})
+	schema, table = s.Qualify("billing.invoices")
+	require.Equal(t, []string{"billing", "invoices"}, []string{schema, table})
+}
@@ -0,0 +1,38 @@
+package sqlite
+
+import (
+	"context"
+	"strings"
+
+	"github.com/quenbyako/ext/slices"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+type dialect struct{}
+
+var _ dbenv.Dialect = dialect{}
+
+// Dialect returns SQLite SQL dialect.
+func Dialect() dbenv.Dialect { return dialect{} }
+
+func (dialect) QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (dialect) Placeholder(int) string { return "?" }
+
+func (dialect) Tables(ctx context.Context, q dbenv.Queryer) (map[string]dbenv.TableSchema, error) {
+	return getAllSchemaTables(ctx, q)
+}
+
+func (dialect) CurrentSchema(context.Context, dbenv.Queryer) (string, error) { return mainSchema, nil }
+
+// Truncate uses DELETE, since SQLite has no TRUNCATE statement at all.
+func (d dialect) Truncate(tables []string) []string {
+	return slices.Remap(tables, func(s string) string { return "DELETE FROM " + dbenv.QuoteName(d, s) })
+}
+
+func (d dialect) BulkInsert(table string, columns []string, rows int) string {
+	return dbenv.BulkInsert(d, table, columns, rows)
+}
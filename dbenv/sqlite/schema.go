@@ -0,0 +1,271 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+const allTablesQuery = `
+SELECT name FROM sqlite_master
+WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+ORDER BY name
+`
+
+type tableColumnsRow struct {
+	CID          int            `db:"cid"`
+	Name         string         `db:"name"`
+	Type         string         `db:"type"`
+	NotNull      bool           `db:"notnull"`
+	DefaultValue sql.NullString `db:"dflt_value"`
+	PK           int            `db:"pk"` // position in primary key, starting from 1
+}
+
+type foreignKeyRow struct {
+	ID       int    `db:"id"`
+	Seq      int    `db:"seq"`
+	Table    string `db:"table"`
+	From     string `db:"from"`
+	To       string `db:"to"`
+	OnUpdate string `db:"on_update"`
+	OnDelete string `db:"on_delete"`
+	Match    string `db:"match"`
+}
+
+// mainSchema is the name of the database, which is opened by connection.
+// Attached databases are not introspected.
+const mainSchema = "main"
+
+// getAllSchemaTables returns the same schema, as postgres introspection does,
+// so fixtures and validators work equally for both databases.
+func getAllSchemaTables(ctx context.Context, tx dbenv.Queryer) (map[string]dbenv.TableSchema, error) {
+	names, err := getTables(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]dbenv.TableSchema, len(names))
+	for _, name := range names {
+		columns, err := getTableColumns(ctx, tx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		foreignKeys, err := getForeignKeys(ctx, tx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		uniques, err := getUniques(ctx, tx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		var schema dbenv.TableSchema
+		pkeys := make(map[int]string)
+		for _, column := range columns {
+			schema.Types = append(schema.Types, dbenv.ColumnType{
+				Name: column.Name,
+				Typ:  normalizeType(column.Type),
+				// sqlite allows nulls in primary keys, but it's a legacy bug,
+				// rather than feature.
+				Nullable: !column.NotNull && column.PK == 0,
+				Default:  column.DefaultValue.String,
+			})
+			if column.PK > 0 {
+				pkeys[column.PK] = column.Name
+			}
+		}
+		for i := 1; i <= len(pkeys); i++ {
+			schema.PrimaryKeys = append(schema.PrimaryKeys, pkeys[i])
+		}
+		// only "INTEGER PRIMARY KEY" column is an alias for rowid, which is
+		// generated automatically.
+		if len(pkeys) == 1 {
+			for i, column := range columns {
+				if column.PK == 1 && strings.EqualFold(column.Type, "integer") {
+					schema.Types[i].Identity = true
+				}
+			}
+		}
+		schema.ForeignKeys = foreignKeys
+		schema.Uniques = uniques
+
+		res[mainSchema+"."+name] = schema
+	}
+
+	return res, nil
+}
+
+func introspectionErr(query string, err error) error {
+	return fmt.Errorf("%w: %w", dbenv.ErrIntrospection, &dbenv.QueryError{Query: query, Err: err})
+}
+
+func getTables(ctx context.Context, tx dbenv.Queryer) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, allTablesQuery)
+	if err != nil {
+		return nil, introspectionErr(allTablesQuery, err)
+	}
+	defer rows.Close()
+
+	var res []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, introspectionErr(allTablesQuery, err)
+		}
+		res = append(res, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, introspectionErr(allTablesQuery, err)
+	}
+
+	return res, nil
+}
+
+func getTableColumns(ctx context.Context, tx dbenv.Queryer, tableName string) ([]tableColumnsRow, error) {
+	// pragma functions can't take table name as parameter
+	query := "SELECT cid, name, type, \"notnull\", dflt_value, pk FROM pragma_table_info('" + strings.ReplaceAll(tableName, "'", "''") + "')"
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, introspectionErr(query, err)
+	}
+	defer rows.Close()
+
+	var res []tableColumnsRow
+	for rows.Next() {
+		var i tableColumnsRow
+		if err := rows.Scan(&i.CID, &i.Name, &i.Type, &i.NotNull, &i.DefaultValue, &i.PK); err != nil {
+			return nil, introspectionErr(query, err)
+		}
+		res = append(res, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, introspectionErr(query, err)
+	}
+
+	return res, nil
+}
+
+func getForeignKeys(ctx context.Context, tx dbenv.Queryer, tableName string) ([]dbenv.ForeignKey, error) {
+	query := "SELECT id, seq, \"table\", \"from\", \"to\", on_update, on_delete, \"match\" FROM pragma_foreign_key_list('" + strings.ReplaceAll(tableName, "'", "''") + "')"
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, introspectionErr(query, err)
+	}
+	defer rows.Close()
+
+	var fkRows []foreignKeyRow
+	for rows.Next() {
+		var i foreignKeyRow
+		var to sql.NullString // null, when foreign key references primary key implicitly
+		if err := rows.Scan(&i.ID, &i.Seq, &i.Table, &i.From, &to, &i.OnUpdate, &i.OnDelete, &i.Match); err != nil {
+			return nil, introspectionErr(query, err)
+		}
+		i.To = to.String
+		fkRows = append(fkRows, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, introspectionErr(query, err)
+	}
+
+	sort.Slice(fkRows, func(a, b int) bool {
+		if fkRows[a].ID != fkRows[b].ID {
+			return fkRows[a].ID < fkRows[b].ID
+		}
+		return fkRows[a].Seq < fkRows[b].Seq
+	})
+
+	var res []dbenv.ForeignKey
+	for i, row := range fkRows {
+		if i == 0 || fkRows[i-1].ID != row.ID {
+			res = append(res, dbenv.ForeignKey{
+				RefTable: mainSchema + "." + row.Table,
+				OnDelete: row.OnDelete,
+				OnUpdate: row.OnUpdate,
+			})
+		}
+		fk := &res[len(res)-1]
+		fk.Columns = append(fk.Columns, row.From)
+		fk.RefColumns = append(fk.RefColumns, row.To)
+	}
+
+	return res, nil
+}
+
+// getUniques returns unique constraints and full unique indexes of the table.
+func getUniques(ctx context.Context, tx dbenv.Queryer, tableName string) ([]dbenv.Unique, error) {
+	query := "SELECT il.name, ii.name FROM pragma_index_list('" + strings.ReplaceAll(tableName, "'", "''") + "') AS il " +
+		"JOIN pragma_index_info(il.name) AS ii " +
+		"WHERE il.\"unique\" AND il.origin <> 'pk' AND NOT il.partial AND ii.name IS NOT NULL " +
+		"ORDER BY il.name, ii.seqno"
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, introspectionErr(query, err)
+	}
+	defer rows.Close()
+
+	var res []dbenv.Unique
+	for rows.Next() {
+		var name, column string
+		if err := rows.Scan(&name, &column); err != nil {
+			return nil, introspectionErr(query, err)
+		}
+
+		if len(res) == 0 || res[len(res)-1].Name != name {
+			res = append(res, dbenv.Unique{Name: name})
+		}
+		res[len(res)-1].Columns = append(res[len(res)-1].Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, introspectionErr(query, err)
+	}
+
+	return res, nil
+}
+
+// normalizeType converts declared SQLite column type to the postgres one, the
+// way SQLite detects column affinity.
+func normalizeType(declared string) string {
+	typ := strings.ToLower(strings.TrimSpace(declared))
+	if i := strings.IndexByte(typ, '('); i >= 0 {
+		typ = strings.TrimSpace(typ[:i])
+	}
+
+	switch typ {
+	case "integer", "smallint", "bigint", "text", "boolean", "real", "double precision", "numeric",
+		"date", "timestamp", "timestamptz", "uuid", "bytea", "json", "jsonb":
+		return typ
+	case "int", "tinyint", "mediumint", "int2", "int8", "unsigned big int":
+		return "integer"
+	case "double", "float":
+		return "double precision"
+	case "bool":
+		return "boolean"
+	case "datetime":
+		return "timestamp"
+	case "blob", "":
+		return "bytea"
+	}
+
+	// https://www.sqlite.org/datatype3.html#determination_of_column_affinity
+	switch {
+	case strings.Contains(typ, "int"):
+		return "integer"
+	case strings.Contains(typ, "char"), strings.Contains(typ, "clob"), strings.Contains(typ, "text"):
+		return "text"
+	case strings.Contains(typ, "blob"):
+		return "bytea"
+	case strings.Contains(typ, "real"), strings.Contains(typ, "floa"), strings.Contains(typ, "doub"):
+		return "double precision"
+	default:
+		return "numeric"
+	}
+}
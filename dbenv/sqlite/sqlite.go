@@ -0,0 +1,176 @@
+// Package sqlite implements dbenv.Container on top of SQLite database, so
+// fixtures and validators can be used without docker at all.
+//
+// Package doesn't import any driver, so it must be imported by caller, e.g.
+// github.com/mattn/go-sqlite3, which is used by default, or any other driver
+// set with WithDriver.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/dbenv/internal/util"
+)
+
+const defaultDriver = "sqlite3"
+
+// Option configures SQLite database.
+type Option func(*config)
+
+type config struct {
+	driverName   string
+	tempFile     bool
+	setupQueries []string
+	params       url.Values
+	schemas      dbenv.Schemas
+	policy       dbenv.FlushPolicy
+}
+
+// WithDriver sets name of the database/sql driver, e.g. "sqlite" for
+// modernc.org/sqlite.
+func WithDriver(name string) Option {
+	return func(c *config) { c.driverName = name }
+}
+
+// WithTempFile stores database in temporary file instead of memory, file is
+// removed on Close.
+func WithTempFile() Option {
+	return func(c *config) { c.tempFile = true }
+}
+
+// WithDSNParams adds driver specific parameters to the connection string,
+// e.g. "_foreign_keys=1" of github.com/mattn/go-sqlite3, which turns foreign
+// key checks on.
+func WithDSNParams(params url.Values) Option {
+	return func(c *config) {
+		if c.params == nil {
+			c.params = make(url.Values)
+		}
+		for k, v := range params {
+			c.params[k] = append(c.params[k], v...)
+		}
+	}
+}
+
+// WithSetupSchema runs queries right after database is created.
+func WithSetupSchema(queries []string) Option {
+	return func(c *config) { c.setupQueries = append(c.setupQueries, queries...) }
+}
+
+// WithSchemas selects tables, which are visible to Flush and Dump. See
+// dbenv.Schemas for details.
+func WithSchemas(schemas dbenv.Schemas) Option {
+	return func(c *config) { c.schemas = schemas }
+}
+
+// WithFlushMode sets dbenv.FlushMode of Flush.
+// Foreign keys are checked only, if they are enabled for connections, e.g.
+// with WithDSNParams.
+func WithFlushMode(mode dbenv.FlushMode) Option {
+	return func(c *config) { c.policy.Mode = mode }
+}
+
+// WithProtected sets dbenv.FlushPolicy.Protected patterns of tables.
+func WithProtected(patterns ...string) Option {
+	return func(c *config) { c.policy.Protected = append(c.policy.Protected, patterns...) }
+}
+
+// container represents SQLite database. Connection is kept open for the whole
+// life of the container, since in-memory database is removed, when last
+// connection is closed.
+type container struct {
+	driverName string
+	dsn        string
+	dir        string // temporary directory, if database is stored in file
+	db         *sql.DB
+	schemas    dbenv.Schemas
+	policy     dbenv.FlushPolicy
+}
+
+var _ dbenv.Container = (*container)(nil)
+
+// New creates new empty SQLite database, in memory by default.
+func New(ctx context.Context, opts ...Option) (_ dbenv.Container, err error) {
+	cfg := config{driverName: defaultDriver}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	c := &container{driverName: cfg.driverName, schemas: cfg.schemas, policy: cfg.policy}
+	if cfg.tempFile {
+		if c.dir, err = os.MkdirTemp("", "sqltest-sqlite-"); err != nil {
+			return nil, err
+		}
+		c.dsn = "file:" + filepath.Join(c.dir, "db.sqlite")
+	} else {
+		// shared cache allows to open the same in-memory database from other
+		// connections, e.g. from the code under test.
+		c.dsn = "file:sqltest_" + strings.ReplaceAll(uuid.NewString(), "-", "") + "?mode=memory&cache=shared"
+	}
+	if len(cfg.params) > 0 {
+		if strings.Contains(c.dsn, "?") {
+			c.dsn += "&" + cfg.params.Encode()
+		} else {
+			c.dsn += "?" + cfg.params.Encode()
+		}
+	}
+
+	if c.db, err = util.ConnectSQLContext(ctx, c.driverName, c.dsn); err != nil {
+		c.removeDir()
+		return nil, err
+	}
+
+	for _, query := range cfg.setupQueries {
+		if _, err := c.db.ExecContext(ctx, query); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("setting up schema: %w", &dbenv.QueryError{Query: query, Err: err})
+		}
+	}
+
+	return c, nil
+}
+
+// NewT creates SQLite database for the test, which is removed on test
+// cleanup. See dbenv.NewT for details.
+func NewT(t testing.TB, opts ...Option) dbenv.Container {
+	t.Helper()
+
+	return dbenv.NewT(t, New, opts...)
+}
+
+func (c *container) ConnString(context.Context) (string, error) { return c.dsn, nil }
+
+func (c *container) Close() error {
+	defer c.removeDir()
+
+	return c.db.Close()
+}
+
+func (c *container) removeDir() {
+	if c.dir != "" {
+		os.RemoveAll(c.dir)
+	}
+}
+
+func (c *container) Flush(ctx context.Context, data map[string][]dbenv.TableRow) error {
+	_, err := util.Flush(ctx, c.db, dialect{}, c.schemas, c.policy, data)
+	return err
+}
+
+func (c *container) Dump(ctx context.Context) (map[string]dbenv.TableData, error) {
+	return util.Dump(ctx, c.db, dialect{}, c.schemas)
+}
+
+func (c *container) Query(ctx context.Context, query string, args ...any) ([]dbenv.TableRow, error) {
+	return util.QueryRows(ctx, c.db, dialect{}, query, args...)
+}
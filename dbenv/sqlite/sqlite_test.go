@@ -0,0 +1,191 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/url"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/dbenv/sqlite"
+	"github.com/quenbyako/sqltest/tabsync"
+)
+
+var schema = []string{
+	`CREATE TABLE groups (id INTEGER PRIMARY KEY, title VARCHAR(64) NOT NULL)`,
+	`CREATE TABLE names (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		group_id INTEGER REFERENCES groups (id) ON DELETE CASCADE,
+		UNIQUE (name, group_id)
+	)`,
+}
+
+// foreignKeys turns foreign key checks of mattn/go-sqlite3 on.
+var foreignKeys = sqlite.WithDSNParams(url.Values{"_foreign_keys": {"1"}})
+
+func TestContainer(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []sqlite.Option
+	}{
+		{name: "Memory"},
+		{name: "File", opts: []sqlite.Option{sqlite.WithTempFile()}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := sqlite.NewT(t, append(tt.opts, sqlite.WithSetupSchema(schema))...)
+
+			// names goes first to check, that insert order is resolved
+			err := tabsync.FlushRaw(c, map[string][]map[string]driver.Value{
+				"names":  {{"id": "1", "name": "alice", "group_id": "1"}, {"id": "2", "name": "bob", "group_id": "1"}},
+				"groups": {{"id": "1", "title": "admins"}},
+			})
+			require.NoError(t, err)
+
+			require.NoError(t, tabsync.ValidateResultCSV(c,
+				"SELECT n.name, g.title FROM names n JOIN groups g ON g.id = n.group_id ORDER BY n.name",
+				strings.NewReader("name,title\nalice,admins\nbob,admins\n"),
+			))
+
+			dump, err := c.Dump(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, dbenv.TableSchema{
+				PrimaryKeys: []string{"id"},
+				Types: []dbenv.ColumnType{
+					{Name: "id", Typ: "integer", Identity: true},
+					{Name: "name", Typ: "text"},
+					{Name: "group_id", Typ: "integer", Nullable: true},
+				},
+				ForeignKeys: []dbenv.ForeignKey{{
+					Columns:    []string{"group_id"},
+					RefTable:   "groups",
+					RefColumns: []string{"id"},
+					OnDelete:   "CASCADE",
+					OnUpdate:   "NO ACTION",
+				}},
+				Uniques: []dbenv.Unique{{Name: "sqlite_autoindex_names_1", Columns: []string{"name", "group_id"}}},
+			}, dump["names"].Schema)
+			require.Len(t, dump["names"].Rows, 2)
+
+			// second flush replaces all data
+			require.NoError(t, tabsync.FlushRaw(c, map[string][]map[string]driver.Value{
+				"groups": {{"id": "2", "title": "users"}},
+			}))
+			dump, err = c.Dump(context.Background())
+			require.NoError(t, err)
+			require.Empty(t, dump["names"].Rows)
+			require.Len(t, dump["groups"].Rows, 1)
+		})
+	}
+}
+
+func TestFlushUnknownTable(t *testing.T) {
+	c := sqlite.NewT(t, sqlite.WithSetupSchema(schema))
+
+	err := tabsync.FlushRaw(c, map[string][]map[string]driver.Value{"unknown": {{"id": "1"}}})
+	require.ErrorContains(t, err, `table "unknown": not exists in database`)
+}
+
+func TestReservedNames(t *testing.T) {
+	c := sqlite.NewT(t, sqlite.WithSetupSchema([]string{
+		`CREATE TABLE "order" ("Id" INTEGER PRIMARY KEY, "group" TEXT)`,
+	}))
+
+	rows := make([]map[string]driver.Value, 1500) // more, than fits single bulk insert
+	for i := range rows {
+		rows[i] = map[string]driver.Value{"Id": i + 1, "group": "g"}
+	}
+	rows[10] = map[string]driver.Value{"Id": 11} // different columns break batch
+
+	require.NoError(t, tabsync.FlushRaw(c, map[string][]map[string]driver.Value{"order": rows}))
+
+	dump, err := c.Dump(context.Background())
+	require.NoError(t, err)
+	require.Len(t, dump["order"].Rows, 1500)
+	require.Nil(t, dump["order"].Rows[10]["group"])
+	require.EqualValues(t, 1500, dump["order"].Rows[1499]["Id"])
+}
+
+func TestSchemas(t *testing.T) {
+	c := sqlite.NewT(t,
+		sqlite.WithSetupSchema(append(schema, `CREATE TABLE schema_migrations (version INTEGER)`, `INSERT INTO schema_migrations VALUES (1)`)),
+		sqlite.WithSchemas(dbenv.Schemas{Exclude: []string{"*.schema_migrations"}}),
+	)
+
+	// qualified name of the default schema is the same table
+	require.NoError(t, tabsync.FlushRaw(c, map[string][]map[string]driver.Value{
+		"main.groups": {{"id": 1, "title": "admins"}},
+	}))
+
+	dump, err := c.Dump(context.Background())
+	require.NoError(t, err)
+	require.NotContains(t, dump, "schema_migrations")
+	require.Len(t, dump["groups"].Rows, 1)
+
+	// excluded tables are not touched by flush
+	rows, err := c.Query(context.Background(), "SELECT version FROM schema_migrations")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+
+	err = tabsync.FlushRaw(c, map[string][]map[string]driver.Value{"schema_migrations": {}})
+	require.ErrorContains(t, err, `table "schema_migrations": not exists in database`)
+}
+
+func TestFlushModes(t *testing.T) {
+	setup := append(schema,
+		`CREATE TABLE kinds (id INTEGER PRIMARY KEY)`,
+		`INSERT INTO kinds VALUES (1)`,
+		`INSERT INTO groups VALUES (1, 'admins')`,
+		`INSERT INTO names VALUES (1, 'John', 1)`,
+	)
+
+	for _, tt := range []struct {
+		name string
+		opts []sqlite.Option
+		want map[string]int // number of rows in table after flush
+	}{
+		{name: "All", want: map[string]int{"groups": 1, "names": 0, "kinds": 0}},
+		// names reference removed group, so they are removed by ON DELETE CASCADE.
+		{name: "Listed", opts: []sqlite.Option{sqlite.WithFlushMode(dbenv.FlushListed)}, want: map[string]int{"groups": 1, "names": 0, "kinds": 1}},
+		{name: "Cascade", opts: []sqlite.Option{sqlite.WithFlushMode(dbenv.FlushCascade)}, want: map[string]int{"groups": 1, "names": 0, "kinds": 1}},
+		{name: "Protected", opts: []sqlite.Option{sqlite.WithProtected("*.kinds")}, want: map[string]int{"groups": 1, "names": 0, "kinds": 1}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := sqlite.NewT(t, append(tt.opts, sqlite.WithSetupSchema(setup), foreignKeys)...)
+
+			require.NoError(t, tabsync.FlushRaw(c, map[string][]map[string]driver.Value{
+				"groups": {{"id": 2, "title": "users"}},
+			}))
+
+			dump, err := c.Dump(context.Background())
+			require.NoError(t, err)
+			for table, want := range tt.want {
+				require.Len(t, dump[table].Rows, want, table)
+			}
+		})
+	}
+
+	c := sqlite.NewT(t, sqlite.WithSetupSchema(setup), sqlite.WithProtected("*.kinds"))
+	err := tabsync.FlushRaw(c, map[string][]map[string]driver.Value{"kinds": {{"id": 2}}})
+	require.ErrorContains(t, err, `table "kinds": protected from flush`)
+}
+
+func TestFlushUnrelatedCycle(t *testing.T) {
+	setup := append(schema,
+		`CREATE TABLE a (id INTEGER PRIMARY KEY, b_id INTEGER REFERENCES b (id))`,
+		`CREATE TABLE b (id INTEGER PRIMARY KEY, a_id INTEGER REFERENCES a (id))`,
+	)
+	data := map[string][]map[string]driver.Value{"groups": {{"id": 1, "title": "admins"}}}
+
+	c := sqlite.NewT(t, sqlite.WithSetupSchema(setup), sqlite.WithFlushMode(dbenv.FlushListed))
+	require.NoError(t, tabsync.FlushRaw(c, data))
+
+	c = sqlite.NewT(t, sqlite.WithSetupSchema(setup))
+	var cycle *dbenv.CycleError
+	require.ErrorAs(t, tabsync.FlushRaw(c, data), &cycle)
+	require.Equal(t, []string{"a", "b", "a"}, cycle.Tables)
+}
@@ -0,0 +1,140 @@
+package dbenv
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Factory creates new container, e.g. postgres.New.
+type Factory[O any] func(ctx context.Context, opts ...O) (Container, error)
+
+// Terminator is implemented by containers, which can be removed completely,
+// not only stopped, as Close does.
+type Terminator interface {
+	Terminate(context.Context) error
+}
+
+// ContextCloser is implemented by containers, which Close can be limited by
+// context, e.g. by timeout of test cleanup.
+type ContextCloser interface {
+	CloseContext(context.Context) error
+}
+
+// LogStreamer is implemented by containers, which can forward their output.
+// Returned stop function must block until streaming is finished.
+type LogStreamer interface {
+	StreamLogs(ctx context.Context, w io.Writer) (stop func() error, err error)
+}
+
+const (
+	// default timeout for container setup, if test doesn't have any deadline.
+	defaultTestTimeout = 5 * time.Minute
+	// termination runs after the test, so test deadline might be already
+	// exceeded.
+	defaultTerminateTimeout = 30 * time.Second
+)
+
+// NewT creates container with factory, and registers its termination in test
+// cleanup. Container logs are written to test log. Any setup error fails the
+// test immediately. Setup is limited by test deadline, if it's set.
+func NewT[O any](t testing.TB, factory Factory[O], opts ...O) Container {
+	t.Helper()
+
+	ctx, cancel := testContext(t)
+	defer cancel()
+
+	c, err := factory(ctx, opts...)
+	if err != nil {
+		t.Fatalf("can't create database container: %v", err)
+	}
+
+	var stopLogs func() error
+	if s, ok := c.(LogStreamer); ok {
+		if stopLogs, err = s.StreamLogs(context.Background(), testLogWriter{t}); err != nil {
+			t.Logf("can't stream container logs: %v", err)
+		}
+	}
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultTerminateTimeout)
+		defer cancel()
+
+		if stopLogs != nil {
+			if err := stopLogs(); err != nil {
+				t.Errorf("can't stop streaming container logs: %v", err)
+			}
+		}
+
+		if err := closeContainer(ctx, c); err != nil {
+			t.Errorf("can't terminate database container: %v", err)
+		}
+	})
+
+	return c
+}
+
+// CloneT creates separate database from the snapshot of the container, which
+// is dropped on test cleanup. Container must implement Snapshotter.
+func CloneT(t testing.TB, c Container, snapshot string) Container {
+	t.Helper()
+
+	s, ok := c.(Snapshotter)
+	if !ok {
+		t.Fatalf("container %T doesn't support snapshots", c)
+	}
+
+	ctx, cancel := testContext(t)
+	defer cancel()
+
+	cloned, err := s.Clone(ctx, snapshot)
+	if err != nil {
+		t.Fatalf("can't clone database from snapshot %#v: %v", snapshot, err)
+	}
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultTerminateTimeout)
+		defer cancel()
+
+		if err := closeContext(ctx, cloned); err != nil {
+			t.Errorf("can't drop cloned database: %v", err)
+		}
+	})
+
+	return cloned
+}
+
+func closeContainer(ctx context.Context, c Container) error {
+	if terminator, ok := c.(Terminator); ok {
+		return terminator.Terminate(ctx)
+	}
+
+	return closeContext(ctx, c)
+}
+
+func closeContext(ctx context.Context, c Container) error {
+	if closer, ok := c.(ContextCloser); ok {
+		return closer.CloseContext(ctx)
+	}
+
+	return c.Close()
+}
+
+func testContext(t testing.TB) (context.Context, context.CancelFunc) {
+	if d, ok := t.(interface{ Deadline() (time.Time, bool) }); ok {
+		if deadline, ok := d.Deadline(); ok {
+			return context.WithDeadline(context.Background(), deadline)
+		}
+	}
+
+	return context.WithTimeout(context.Background(), defaultTestTimeout)
+}
+
+type testLogWriter struct{ t testing.TB }
+
+func (w testLogWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
@@ -0,0 +1,58 @@
+package dbenv_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/internal/fakedb"
+)
+
+func TestNewT(t *testing.T) {
+	var c *fakedb.Container
+	factory := func(_ context.Context, opts ...string) (dbenv.Container, error) {
+		c = &fakedb.Container{Opts: opts}
+		return c, nil
+	}
+
+	t.Run("test", func(t *testing.T) {
+		got := dbenv.NewT(t, factory, "a", "b")
+		require.Same(t, c, got)
+		require.Equal(t, []string{"a", "b"}, c.Opts)
+		require.False(t, c.Terminated)
+	})
+
+	require.True(t, c.Terminated, "container must be terminated on test cleanup")
+	require.False(t, c.Deadline.IsZero(), "termination must be limited by timeout")
+}
+
+type fakeSnapshotter struct {
+	dbenv.Container
+
+	clones map[string]*fakedb.Container
+}
+
+func (s *fakeSnapshotter) Snapshot(context.Context, string) error { return nil }
+func (s *fakeSnapshotter) Restore(context.Context, string) error  { return nil }
+
+func (s *fakeSnapshotter) Clone(_ context.Context, snapshot string) (dbenv.Container, error) {
+	c := &fakedb.Container{}
+	s.clones[snapshot] = c
+
+	return c, nil
+}
+
+func TestCloneT(t *testing.T) {
+	s := &fakeSnapshotter{clones: map[string]*fakedb.Container{}}
+
+	t.Run("test", func(t *testing.T) {
+		got := dbenv.CloneT(t, s, "setup")
+		require.Same(t, s.clones["setup"], got)
+		require.False(t, s.clones["setup"].Closed)
+	})
+
+	require.True(t, s.clones["setup"].Closed, "cloned database must be dropped on test cleanup")
+	require.False(t, s.clones["setup"].Deadline.IsZero(), "dropping must be limited by timeout")
+}
@@ -10,19 +10,22 @@ import (
 	"github.com/quenbyako/sqltest/dbenv/postgres"
 	"github.com/quenbyako/sqltest/tabsync"
 	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
 )
 
 func TestCustom(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
 	ctx := context.Background()
-	c, err := postgres.New(ctx, postgres.WithSetupSchema(nil, []string{
-		"CREATE TABLE names (id integer, name text, group_id integer",
+	c := postgres.NewT(t, postgres.WithSetupSchema([]string{
+		"CREATE TABLE names (id integer, name text, group_id integer)",
 		"CREATE TABLE groups (id integer, name text)",
+		"ALTER TABLE groups ADD CONSTRAINT groups_pkey PRIMARY KEY (id)",
 		"ALTER TABLE names ADD CONSTRAINT names_pkey PRIMARY KEY (id)",
 		"ALTER TABLE names ADD CONSTRAINT names_group_id_fkey FOREIGN KEY (group_id) REFERENCES groups (id)",
 	}))
-	require.NoError(t, err)
 
-	tabsync.FlushRaw(c, map[string][]map[string]driver.Value{
+	err := tabsync.FlushRaw(c, map[string][]map[string]driver.Value{
 		"names": {
 			{"id": 1, "name": "John", "group_id": 1},
 			{"id": 2, "name": "Jane", "group_id": 2},
@@ -32,6 +35,7 @@ func TestCustom(t *testing.T) {
 			{"id": 2, "name": "Users"},
 		},
 	})
+	require.NoError(t, err)
 
 	conn, err := dbenv.SetupConn(ctx, c, "pgx")
 	require.NoError(t, err)
@@ -6,12 +6,16 @@ require (
 	github.com/docker/docker v24.0.7+incompatible
 	github.com/docker/go-connections v0.4.0
 	github.com/expr-lang/expr v1.15.7
+	github.com/go-sql-driver/mysql v1.7.1
+	github.com/gofrs/flock v0.8.1
 	github.com/google/uuid v1.3.1
 	github.com/jackc/pgx/v4 v4.18.1
 	github.com/k0kubun/pp v3.0.1+incompatible
+	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/quenbyako/ext v0.0.0-20231207023143-5b8f70141f2e
 	github.com/stretchr/testify v1.8.4
 	github.com/testcontainers/testcontainers-go v0.26.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -68,5 +72,4 @@ require (
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20230525234030-28d5490b6b19 // indirect
 	google.golang.org/grpc v1.57.1 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,100 @@
+// Package fakedb implements in-memory dbenv.Container and dbenv.Dialect,
+// which are shared by tests of other packages, so they don't need a database.
+package fakedb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+// Container keeps flushed data in memory, and returns it back on Dump.
+type Container struct {
+	Schema  map[string]dbenv.TableSchema
+	Flushed map[string][]dbenv.TableRow
+	Results map[string][]dbenv.TableRow // query -> result
+
+	Opts       []string // options of the factory, which created container
+	Terminated bool
+	Closed     bool
+	Deadline   time.Time // deadline of context, which Terminate or CloseContext got
+}
+
+var (
+	_ dbenv.Container     = (*Container)(nil)
+	_ dbenv.Terminator    = (*Container)(nil)
+	_ dbenv.ContextCloser = (*Container)(nil)
+)
+
+func (c *Container) ConnString(context.Context) (string, error) { return "", nil }
+
+func (c *Container) Close() error {
+	c.Closed = true
+	return nil
+}
+
+func (c *Container) CloseContext(ctx context.Context) error {
+	c.Deadline, _ = ctx.Deadline()
+	return c.Close()
+}
+
+func (c *Container) Terminate(ctx context.Context) error {
+	c.Terminated = true
+	c.Deadline, _ = ctx.Deadline()
+	return nil
+}
+
+func (c *Container) Flush(_ context.Context, data map[string][]dbenv.TableRow) error {
+	c.Flushed = data
+	return nil
+}
+
+func (c *Container) Dump(context.Context) (map[string]dbenv.TableData, error) {
+	res := make(map[string]dbenv.TableData, len(c.Schema))
+	for name, schema := range c.Schema {
+		res[name] = dbenv.TableData{Schema: schema, Rows: c.Flushed[name]}
+	}
+
+	return res, nil
+}
+
+func (c *Container) Query(_ context.Context, query string, _ ...any) ([]dbenv.TableRow, error) {
+	rows, ok := c.Results[query]
+	if !ok {
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+
+	return rows, nil
+}
+
+// Dialect is postgres-like dialect, which returns Schema as tables of the
+// database, and "public" as the current schema.
+type Dialect struct {
+	Schema map[string]dbenv.TableSchema
+}
+
+var _ dbenv.Dialect = Dialect{}
+
+func (Dialect) QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (Dialect) Placeholder(i int) string { return "$" + strconv.Itoa(i) }
+
+func (d Dialect) Tables(context.Context, dbenv.Queryer) (map[string]dbenv.TableSchema, error) {
+	return d.Schema, nil
+}
+
+func (Dialect) CurrentSchema(context.Context, dbenv.Queryer) (string, error) {
+	return "public", nil
+}
+
+func (Dialect) Truncate([]string) []string { return nil }
+
+func (d Dialect) BulkInsert(table string, columns []string, rows int) string {
+	return dbenv.BulkInsert(d, table, columns, rows)
+}
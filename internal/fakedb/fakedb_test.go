@@ -0,0 +1,56 @@
+package fakedb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/internal/fakedb"
+)
+
+func TestContainer(t *testing.T) {
+	ctx := context.Background()
+	c := &fakedb.Container{
+		Schema:  map[string]dbenv.TableSchema{"users": {PrimaryKeys: []string{"id"}}, "groups": {}},
+		Results: map[string][]dbenv.TableRow{"SELECT 1": {{"?column?": int64(1)}}},
+	}
+
+	require.NoError(t, c.Flush(ctx, map[string][]dbenv.TableRow{"users": {{"id": int64(1)}}}))
+	got, err := c.Dump(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]dbenv.TableData{
+		"users":  {Schema: dbenv.TableSchema{PrimaryKeys: []string{"id"}}, Rows: []dbenv.TableRow{{"id": int64(1)}}},
+		"groups": {},
+	}, got)
+
+	rows, err := c.Query(ctx, "SELECT 1")
+	require.NoError(t, err)
+	require.Equal(t, []dbenv.TableRow{{"?column?": int64(1)}}, rows)
+	_, err = c.Query(ctx, "SELECT 2")
+	require.EqualError(t, err, `unexpected query "SELECT 2"`)
+
+	require.NoError(t, c.Close())
+	require.True(t, c.Closed)
+	require.NoError(t, c.Terminate(ctx))
+	require.True(t, c.Terminated)
+}
+
+func TestDialect(t *testing.T) {
+	ctx := context.Background()
+	schema := map[string]dbenv.TableSchema{"public.users": {PrimaryKeys: []string{"id"}}}
+	d := fakedb.Dialect{Schema: schema}
+
+	tables, err := d.Tables(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, schema, tables)
+
+	current, err := d.CurrentSchema(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, "public", current)
+
+	require.Equal(t, `"a""b"`, d.QuoteIdent(`a"b`))
+	require.Equal(t, "$2", d.Placeholder(2))
+	require.Equal(t, `INSERT INTO "users" ("id") VALUES ($1), ($2)`, d.BulkInsert("users", []string{"id"}, 2))
+}
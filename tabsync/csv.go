@@ -0,0 +1,78 @@
+package tabsync
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// default type for columns, which header doesn't declare any type.
+const defaultColumnType = "text"
+
+type csvColumn struct{ name, typ string }
+
+// parseCSVHeader parses header row, where each column can be declared with its
+// type, e.g. "id:int,name:text,parent_id:?int".
+func parseCSVHeader(header []string) ([]csvColumn, error) {
+	res := make([]csvColumn, len(header))
+	seen := make(map[string]struct{}, len(header))
+	for i, field := range header {
+		name, typ, ok := strings.Cut(field, ":")
+		if name = strings.TrimSpace(name); name == "" {
+			return nil, fmt.Errorf("column %v: empty name", i)
+		}
+		if typ = strings.TrimSpace(typ); !ok || typ == "" {
+			typ = defaultColumnType
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("column %#v: declared twice", name)
+		}
+		seen[name] = struct{}{}
+
+		res[i] = csvColumn{name: name, typ: typ}
+	}
+
+	return res, nil
+}
+
+// readCSV reads whole table from r, converting every cell with parse function.
+// First row of the table is always a header.
+func readCSV[T any](r io.Reader, parse func(column, typ, s string) (T, error)) ([]map[string]T, error) {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("header is missing")
+	} else if err != nil {
+		return nil, err
+	}
+
+	columns, err := parseCSVHeader(header)
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+
+	var res []map[string]T
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return res, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]T, len(columns))
+		for i, column := range columns {
+			v, err := parse(column.name, column.typ, record[i])
+			if err != nil {
+				line, _ := reader.FieldPos(i)
+				return nil, fmt.Errorf("line %v: column %#v: %w", line, column.name, err)
+			}
+			row[column.name] = v
+		}
+
+		res = append(res, row)
+	}
+}
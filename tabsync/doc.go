@@ -0,0 +1,37 @@
+// Package tabsync fills database containers with fixtures, and validates
+// their state with expected tables.
+//
+// # Expressions
+//
+// Cells of fixtures, which are started with "=", are expressions of
+// github.com/expr-lang/expr. Value expressions (e.g. in FlushCSV) compute
+// value of the cell, and validator expressions (e.g. in ValidateResultCSV, or
+// masks of ValidateGolden) check actual value of the column, which is
+// available as value, while type is the declared type of the column.
+//
+// Besides builtins of expr, like now(), duration("1h"), date("2024-01-02"),
+// len, "matches" and "contains" operators, both kinds of expressions can use:
+//
+//	within(a, b, tolerance)   times differ not more than tolerance, e.g.
+//	                          within(value, now(), "5s")
+//	approx(a, b, tolerance)   numbers differ not more than tolerance
+//	regex(v, pattern)         v matches regular expression
+//	isUUID(v)                 v is a valid uuid
+//	isJSON(v)                 v is a valid json document
+//	isEmail(v)                v is a bare email address
+//	jsonPath(v, path)         value of json document, e.g.
+//	                          jsonPath(value, "$.items[0].name"), or nil
+//	length(v)                 count of elements of array, json array or
+//	                          object, or length of text
+//	has(v, elem)              array contains elem, json object contains
+//	                          elem key, or text contains elem
+//
+// Times can be compared and shifted as usual: value > now() - duration("1h").
+//
+// Value expressions can also generate values:
+//
+//	seq()                     1, 2, 3... for each row of the column in file
+//	randInt(min, max)         random integer in [min, max]
+//	randString(n)             random alphanumeric string of n characters
+//	uuid()                    random uuid
+package tabsync
@@ -0,0 +1,390 @@
+package tabsync
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/quenbyako/ext/slices"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+// ErrNoColumn is an error of the cell, which is expected, but doesn't exist in
+// the row.
+var ErrNoColumn = errors.New("not found")
+
+// ValidationError describes every mismatch between expected and actual rows,
+// found by validation. It can be extracted with errors.As, so custom reporters
+// can inspect mismatches per table, row and column.
+type ValidationError struct {
+	Tables []TableMismatch
+}
+
+// TableMismatch is a set of mismatches of single table or query result.
+type TableMismatch struct {
+	// Table is empty for query results.
+	Table string
+	// Section is a kind of changes, e.g. "inserted", if rows are validated by
+	// ValidateChangesRaw.
+	Section string
+	// Keys are columns, which are used to match rows.
+	Keys []string
+	// Errors are problems of the whole table, e.g. it doesn't exist.
+	Errors []error
+	Rows   []RowMismatch
+}
+
+// RowKind describes, what is wrong with the row.
+type RowKind int
+
+const (
+	// RowDiffers is a row, which is found, but some of its cells don't pass
+	// validators.
+	RowDiffers RowKind = iota
+	// RowMissing is an expected row, which is not found. Got contains the
+	// closest row for tables without keys.
+	RowMissing
+	// RowUnexpected is an actual row, which is not expected in strict mode.
+	RowUnexpected
+	// RowForbidden is a row, which is marked with NotExists, but exists.
+	RowForbidden
+	// RowInvalid is an expected row, which can't be matched, e.g. its primary
+	// key is an expression.
+	RowInvalid
+)
+
+// RowMismatch is single mismatched row.
+type RowMismatch struct {
+	Kind RowKind
+	// Row identifies the row in messages: values of keys, or index of expected
+	// row, if table has no keys.
+	Row any
+	// Want is nil for unexpected rows.
+	Want map[string]Validator
+	// Got is nil, if there is no actual row.
+	Got   dbenv.TableRow
+	Cells []CellMismatch
+	// Err is set for invalid rows.
+	Err error
+}
+
+// CellMismatch is single value, which doesn't pass validator.
+type CellMismatch struct {
+	Column string
+	Got    driver.Value
+	Want   Validator
+	// Err is ErrNoColumn, if there is no such column in the row.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	var lines []string
+	for _, t := range e.Tables {
+		var prefix string
+		if t.Table != "" {
+			prefix = fmt.Sprintf("table %#v: ", t.Table)
+		}
+		if t.Section != "" {
+			prefix += t.Section + " rows: "
+		}
+
+		for _, line := range t.lines() {
+			lines = append(lines, prefix+line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Format prints side-by-side diff for "%+v" verb, and error message
+// otherwise.
+func (e *ValidationError) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('+') {
+		fmt.Fprint(f, e.Diff(false))
+		return
+	}
+
+	fmt.Fprint(f, e.Error())
+}
+
+func (t TableMismatch) lines() []string {
+	var res []string
+	for _, err := range t.Errors {
+		res = append(res, err.Error())
+	}
+
+	for _, row := range t.Rows {
+		switch row.Kind {
+		case RowMissing:
+			res = append(res, fmt.Sprintf("row %#v: not found in database", row.Row))
+		case RowUnexpected:
+			res = append(res, fmt.Sprintf("row %#v: not expected", row.Row))
+		case RowForbidden:
+			res = append(res, fmt.Sprintf("row %#v: exists in database, but must not", row.Row))
+		case RowInvalid:
+			res = append(res, row.Err.Error())
+		}
+
+		for _, cell := range row.Cells {
+			res = append(res, fmt.Sprintf("row %#v: key %q: %v", row.Row, cell.Column, cell.Err))
+		}
+	}
+
+	return res
+}
+
+func (t TableMismatch) empty() bool { return len(t.Errors) == 0 && len(t.Rows) == 0 }
+
+// err returns nil, if there is no mismatches.
+func (t TableMismatch) err() error {
+	if t.empty() {
+		return nil
+	}
+
+	return &ValidationError{Tables: []TableMismatch{t}}
+}
+
+// err returns nil, if there is no mismatches.
+func (e *ValidationError) err() error {
+	if len(e.Tables) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+)
+
+// row markers of the diff.
+var rowMarkers = map[RowKind]string{
+	RowDiffers:    "~",
+	RowMissing:    "-",
+	RowUnexpected: "+",
+	RowForbidden:  "!",
+	RowInvalid:    "?",
+}
+
+// Diff renders mismatches of every table as side-by-side table of expected
+// and actual rows. Mismatched cells are marked with "*", or highlighted with
+// ANSI colors, if color is true. Rows are marked as "~" (differs), "-"
+// (missing), "+" (unexpected) and "!" (must not exist).
+func (e *ValidationError) Diff(color bool) string {
+	var b strings.Builder
+	for i, t := range e.Tables {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		t.render(&b, color)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// diffCell is a cell of rendered diff. Mismatched cells are marked, and cells
+// of missing or unexpected rows are only colored.
+type diffCell struct {
+	text  string
+	mark  bool
+	color string // ANSI code
+}
+
+func (t TableMismatch) render(b *strings.Builder, color bool) {
+	title := "result"
+	if t.Table != "" {
+		title = fmt.Sprintf("table %#v", t.Table)
+	}
+	if t.Section != "" {
+		title += " (" + t.Section + " rows)"
+	}
+	b.WriteString(paint(color, ansiBold, title+":"))
+	b.WriteByte('\n')
+
+	columns := t.columns()
+	var rows [][]diffCell
+	var notes []string
+	for _, err := range t.Errors {
+		notes = append(notes, err.Error())
+	}
+
+	for _, row := range t.Rows {
+		if row.Kind == RowInvalid {
+			notes = append(notes, row.Err.Error())
+			continue
+		}
+
+		bad := make(map[string]bool, len(row.Cells))
+		for _, cell := range row.Cells {
+			bad[cell.Column] = true
+		}
+
+		var marker, wantColor, gotColor string
+		switch row.Kind {
+		case RowMissing:
+			marker, wantColor = ansiGreen, ansiGreen
+		case RowUnexpected, RowForbidden:
+			marker, gotColor = ansiRed, ansiRed
+		}
+
+		cells := []diffCell{{text: rowMarkers[row.Kind], color: marker}}
+		for _, column := range columns {
+			var c diffCell
+			if v, ok := row.Want[column]; ok {
+				c = diffCell{text: describeValidator(v), mark: bad[column], color: wantColor}
+				if c.mark {
+					c.color = ansiGreen
+				}
+			}
+			cells = append(cells, c)
+		}
+		cells = append(cells, diffCell{text: "|"})
+		for _, column := range columns {
+			var c diffCell
+			if v, ok := row.Got[column]; ok {
+				c = diffCell{text: describeValue(v), mark: bad[column], color: gotColor}
+				if c.mark {
+					c.color = ansiRed
+				}
+			}
+			cells = append(cells, c)
+		}
+		rows = append(rows, cells)
+	}
+
+	if len(rows) > 0 {
+		header := []diffCell{{}}
+		for _, column := range columns {
+			header = append(header, diffCell{text: column})
+		}
+		header = append(header, diffCell{text: "|"})
+		for _, column := range columns {
+			header = append(header, diffCell{text: column})
+		}
+
+		writeGrid(b, color, append([][]diffCell{header}, rows...))
+	}
+
+	for _, note := range notes {
+		b.WriteString("  ")
+		b.WriteString(paint(color, ansiRed, note))
+		b.WriteByte('\n')
+	}
+}
+
+// columns returns all columns of expected and actual rows, keys go first.
+func (t TableMismatch) columns() []string {
+	seen := make(map[string]bool)
+	for _, row := range t.Rows {
+		for column := range row.Want {
+			seen[column] = true
+		}
+		for column := range row.Got {
+			seen[column] = true
+		}
+	}
+
+	var rest []string
+	for column := range seen {
+		if !slices.Contains(t.Keys, column) {
+			rest = append(rest, column)
+		}
+	}
+	sort.Strings(rest)
+
+	var res []string
+	for _, key := range t.Keys {
+		if seen[key] {
+			res = append(res, key)
+		}
+	}
+
+	return append(res, rest...)
+}
+
+// writeGrid writes aligned rows. First row is a header.
+func writeGrid(b *strings.Builder, color bool, rows [][]diffCell) {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell.text)+boolInt(cell.mark && !color))
+		}
+	}
+
+	for r, row := range rows {
+		var line strings.Builder
+		line.WriteString("  ")
+		for i, cell := range row {
+			if i > 0 {
+				line.WriteByte(' ')
+			}
+
+			text := cell.text
+			if cell.mark && !color {
+				text += "*"
+			}
+			pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(text))
+
+			if r == 0 {
+				line.WriteString(paint(color, ansiBold, text))
+			} else {
+				line.WriteString(paint(color, cell.color, text))
+			}
+			line.WriteString(pad)
+		}
+
+		b.WriteString(strings.TrimRight(line.String(), " "))
+		b.WriteByte('\n')
+	}
+}
+
+func paint(color bool, code, s string) string {
+	if !color || code == "" || s == "" {
+		return s
+	}
+
+	return code + s + ansiReset
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func describeValidator(v Validator) string {
+	switch v := v.(type) {
+	case absentValidator:
+		return describeValidator(v.Validator)
+	case constValidator:
+		return describeValue(v.value)
+	case exprValidator:
+		return "=" + v.prog.Source().Content()
+	default:
+		if value, ok := v.AsValue(); ok {
+			return describeValue(value)
+		}
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func describeValue(v driver.Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []byte:
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,345 @@
+package tabsync
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// exprFuncs are functions of both value and validator expressions (see
+// package documentation). typ is a column type, which is used to recognize
+// arrays and json values.
+func exprFuncs(typ string) map[string]any {
+	return map[string]any{
+		"within":   within,
+		"approx":   approx,
+		"regex":    matchRegex,
+		"isUUID":   isUUID,
+		"isJSON":   isJSON,
+		"isEmail":  isEmail,
+		"jsonPath": jsonPath,
+		"length":   func(v any) (int, error) { return length(typ, v) },
+		"has":      func(v, elem any) (bool, error) { return has(typ, v, elem) },
+	}
+}
+
+// generatorFuncs are functions of value expressions, which generate values of
+// fixtures. seq counts values of single column.
+func generatorFuncs(seq *int64) map[string]any {
+	return map[string]any{
+		"seq":        func() int64 { *seq++; return *seq },
+		"randInt":    func(min, max int) int { return min + rand.Intn(max-min+1) },
+		"randString": randString,
+		"uuid":       uuid.NewString,
+	}
+}
+
+func within(a, b, tolerance any) (bool, error) {
+	aTime, err := exprTime(a)
+	if err != nil {
+		return false, err
+	}
+	bTime, err := exprTime(b)
+	if err != nil {
+		return false, err
+	}
+
+	var d time.Duration
+	switch tolerance := tolerance.(type) {
+	case time.Duration:
+		d = tolerance
+	case string:
+		if d, err = time.ParseDuration(tolerance); err != nil {
+			return false, err
+		}
+	default:
+		return false, fmt.Errorf("invalid tolerance %#v, expected duration", tolerance)
+	}
+
+	diff := aTime.Sub(bTime)
+	return diff <= d && diff >= -d, nil
+}
+
+func exprTime(v any) (time.Time, error) {
+	if v == nil {
+		return time.Time{}, errors.New("expected time, got nil")
+	}
+
+	return convertTime(v)
+}
+
+func approx(a, b, tolerance any) (bool, error) {
+	var values [3]float64
+	for i, v := range []any{a, b, tolerance} {
+		f, err := exprFloat(v)
+		if err != nil {
+			return false, err
+		}
+		values[i] = f
+	}
+
+	return math.Abs(values[0]-values[1]) <= values[2], nil
+}
+
+func exprFloat(v any) (float64, error) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case []byte:
+		return strconv.ParseFloat(string(v), 64)
+	default:
+		return 0, fmt.Errorf("expected number, got %#v", v)
+	}
+}
+
+// exprText returns text of string-like values. Other values are formatted.
+func exprText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func matchRegex(v any, pattern string) (bool, error) {
+	if v == nil {
+		return false, nil
+	}
+
+	return regexp.MatchString(pattern, exprText(v))
+}
+
+func isUUID(v any) bool {
+	if v == nil {
+		return false
+	}
+
+	_, err := uuid.Parse(exprText(v))
+	return err == nil
+}
+
+func isJSON(v any) bool {
+	return v != nil && json.Valid([]byte(exprText(v)))
+}
+
+func isEmail(v any) bool {
+	if v == nil {
+		return false
+	}
+
+	addr, err := mail.ParseAddress(exprText(v))
+	return err == nil && addr.Address == exprText(v)
+}
+
+// jsonPath returns value of json document by path, like "$.items[0].name" or
+// "$['first name']". Missing values are nil.
+func jsonPath(v any, path string) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	var doc any
+	if err := json.Unmarshal([]byte(exprText(v)), &doc); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("invalid json path %#v: must start with \"$\"", path)
+	}
+
+	for rest != "" {
+		var key string
+		var index int
+		isIndex := false
+
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key, rest = rest[:end], rest[end:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid json path %#v: unclosed bracket", path)
+			}
+			part := rest[1:end]
+			rest = rest[end+1:]
+
+			if unquoted, err := strconv.Unquote(strings.ReplaceAll(part, "'", `"`)); err == nil {
+				key = unquoted
+			} else if index, err = strconv.Atoi(part); err == nil {
+				isIndex = true
+			} else {
+				return nil, fmt.Errorf("invalid json path %#v: invalid index %#v", path, part)
+			}
+		default:
+			return nil, fmt.Errorf("invalid json path %#v", path)
+		}
+
+		switch node := doc.(type) {
+		case map[string]any:
+			if isIndex {
+				return nil, nil
+			}
+			doc = node[key]
+		case []any:
+			if !isIndex || index < 0 || index >= len(node) {
+				return nil, nil
+			}
+			doc = node[index]
+		default:
+			return nil, nil
+		}
+	}
+
+	return doc, nil
+}
+
+// valueKind recognizes arrays and json values by column type.
+func valueKind(typ string) string {
+	typ = normalizeTypeName(strings.TrimPrefix(typ, "?"))
+	switch {
+	case typ == "json" || typ == "jsonb":
+		return "json"
+	case typ == "array" || strings.HasSuffix(typ, "[]") || strings.HasPrefix(typ, "_"):
+		return "array"
+	default:
+		return ""
+	}
+}
+
+// length returns count of elements of arrays, json arrays and objects, or
+// length of text.
+func length(typ string, v any) (int, error) {
+	if v == nil {
+		return 0, errors.New("length of nil value")
+	}
+
+	switch valueKind(typ) {
+	case "array":
+		items, err := parseArray(exprText(v))
+		return len(items), err
+	case "json":
+		var doc any
+		if err := json.Unmarshal([]byte(exprText(v)), &doc); err != nil {
+			return 0, fmt.Errorf("invalid json: %w", err)
+		}
+		switch doc := doc.(type) {
+		case nil:
+			return 0, nil
+		case []any:
+			return len(doc), nil
+		case map[string]any:
+			return len(doc), nil
+		case string:
+			return utf8.RuneCountInString(doc), nil
+		default:
+			return 0, fmt.Errorf("length of json %T", doc)
+		}
+	}
+
+	switch v := v.(type) {
+	case []byte:
+		return len(v), nil
+	case string:
+		return utf8.RuneCountInString(v), nil
+	default:
+		return 0, fmt.Errorf("length of %T", v)
+	}
+}
+
+// has checks, that array contains the element, json object contains the key,
+// or text contains the substring. Elements are compared by their text.
+func has(typ string, v, elem any) (bool, error) {
+	if v == nil {
+		return false, nil
+	}
+
+	switch valueKind(typ) {
+	case "array":
+		items, err := parseArray(exprText(v))
+		if err != nil {
+			return false, err
+		}
+		for _, item := range items {
+			if (item == nil && elem == nil) || (item != nil && elem != nil && *item == exprText(elem)) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "json":
+		var doc any
+		if err := json.Unmarshal([]byte(exprText(v)), &doc); err != nil {
+			return false, fmt.Errorf("invalid json: %w", err)
+		}
+		switch doc := doc.(type) {
+		case []any:
+			for _, item := range doc {
+				if (item == nil && elem == nil) || (item != nil && elem != nil && exprText(item) == exprText(elem)) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]any:
+			_, ok := doc[exprText(elem)]
+			return ok, nil
+		case nil:
+			return false, nil
+		default:
+			return false, fmt.Errorf("can't search in json %T", doc)
+		}
+	}
+
+	return strings.Contains(exprText(v), exprText(elem)), nil
+}
+
+const randLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func randString(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = randLetters[rand.Intn(len(randLetters))]
+	}
+
+	return string(b)
+}
+
+// exprValue converts result of value expression to the fixture value. Nil
+// result is allowed only for nullable types.
+func exprValue(typ string, v any) (driver.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		if !strings.HasPrefix(typ, "?") {
+			return nil, fmt.Errorf("expression returned null for non-nullable type %#v", typ)
+		}
+		return nil, nil
+	case time.Time:
+		return convertTo(typ, v.Format(time.RFC3339Nano))
+	default:
+		return convertTo(typ, exprText(v))
+	}
+}
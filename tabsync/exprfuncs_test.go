@@ -0,0 +1,98 @@
+package tabsync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatorFuncs(t *testing.T) {
+	for _, tt := range []struct {
+		typ     string
+		expr    string
+		value   any
+		wantErr string
+	}{
+		{typ: "timestamptz", expr: `=within(value, now(), "5s")`, value: time.Now().Add(-time.Second)},
+		{typ: "timestamptz", expr: `=within(value, now(), duration("5s"))`, value: time.Now().Add(-time.Minute), wantErr: "fails this expression"},
+		{typ: "timestamp", expr: `=value > now() - duration("1h")`, value: time.Now()},
+		{typ: "numeric", expr: `=approx(value, 0.3, 0.0001)`, value: "0.30001"},
+		{typ: "double precision", expr: `=approx(value, 1, 0.1)`, value: 1.5, wantErr: "fails this expression"},
+		{typ: "text", expr: `=regex(value, "^[a-z]+-[0-9]+$")`, value: "order-42"},
+		{typ: "text", expr: `=isUUID(value)`, value: "0b7b3c3e-8d5c-4a39-9d6c-1f7a2b3c4d5e"},
+		{typ: "text", expr: `=isEmail(value)`, value: "John <john@example.com>", wantErr: "fails this expression"},
+		{typ: "text", expr: `=isJSON(value) && length(value) == 7`, value: `{"a":1}`},
+		{typ: "jsonb", expr: `=jsonPath(value, "$.items[1]['first name']") == "Jane"`, value: []byte(`{"items": [{}, {"first name": "Jane"}]}`)},
+		{typ: "jsonb", expr: `=jsonPath(value, "$.total") == 3 && jsonPath(value, "$.missing") == nil`, value: []byte(`{"total": 3}`)},
+		{typ: "jsonb", expr: `=length(value) == 2 && has(value, "b")`, value: []byte(`{"a": 1, "b": 2}`)},
+		{typ: "int[]", expr: `=length(value) == 3 && has(value, 2) && has(value, nil) && !has(value, 4)`, value: "{1,2,NULL}"},
+		{typ: "?text[]", expr: `=value == nil || has(value, "b c")`, value: `{a,"b c"}`},
+	} {
+		t.Run(tt.expr, func(t *testing.T) {
+			v, err := newValidator(nil)("", tt.typ, tt.expr)
+			require.NoError(t, err)
+
+			err = v.Validate(tt.value)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatorFuncsPrecheck(t *testing.T) {
+	// expressions are compiled, but not evaluated on sample values, so
+	// functions, which fail on null json, are accepted.
+	v, err := newValidator(nil)("", "jsonb", `=jsonPath(value, "$.total") > 1`)
+	require.NoError(t, err)
+	require.NoError(t, v.Validate(`{"total": 2}`))
+
+	v, err = newValidator(nil)("", "jsonb", `=approx(jsonPath(value, "$.price"), 9.99, 0.01)`)
+	require.NoError(t, err)
+	require.NoError(t, v.Validate(`{"price": 9.991}`))
+
+	// invalid arguments are reported, when values are validated.
+	v, err = newValidator(nil)("", "jsonb", `=jsonPath(value, "items") == nil`)
+	require.NoError(t, err)
+	require.ErrorContains(t, v.Validate(`{}`), `invalid json path "items": must start with "$"`)
+
+	// unknown names are still reported, when validator is created.
+	_, err = newValidator(nil)("", "jsonb", `=jsonPth(value, "$.total") > 1`)
+	require.ErrorContains(t, err, `unknown name jsonPth`)
+}
+
+func TestValueFuncs(t *testing.T) {
+	rows, err := readCSV(strings.NewReader(
+		"id:int,token:uuid,code,created_at:timestamptz\n"+
+			`=seq(),=uuid(),=randString(8),=now()`+"\n"+
+			`=seq(),=uuid(),=randString(8),"=date(""2024-01-02"")"`+"\n",
+	), newValueParser())
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	for i, row := range rows {
+		require.Equal(t, int64(i+1), row["id"])
+		require.True(t, isUUID(row["token"]))
+		require.Regexp(t, "^[a-zA-Z0-9]{8}$", row["code"])
+	}
+	require.NotEqual(t, rows[0]["token"], rows[1]["token"])
+	require.WithinDuration(t, time.Now(), rows[0]["created_at"].(time.Time), time.Minute)
+	require.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), rows[1]["created_at"])
+
+	// sequences start again in every file.
+	rows, err = readCSV(strings.NewReader("id:int\n=seq() + 10\n"), newValueParser())
+	require.NoError(t, err)
+	require.Equal(t, int64(11), rows[0]["id"])
+
+	// null results are accepted only by nullable types.
+	rows, err = readCSV(strings.NewReader("note:?text\n=nil\n"), newValueParser())
+	require.NoError(t, err)
+	require.Nil(t, rows[0]["note"])
+
+	_, err = readCSV(strings.NewReader("note:text\n=nil\n"), newValueParser())
+	require.ErrorContains(t, err, `expression returned null for non-nullable type "text"`)
+}
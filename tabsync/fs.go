@@ -0,0 +1,246 @@
+package tabsync
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/quenbyako/ext/slices"
+	"gopkg.in/yaml.v3"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+// appendPrefix marks fixture file, which rows must be appended to the rows of
+// the same table from previous directories, instead of replacing them.
+const appendPrefix = "+"
+
+type decoder func(r io.Reader) ([]map[string]driver.Value, error)
+
+// decoders are selected by file extension. Files with any other extension are
+// ignored, so fixtures directory can contain readme or something like this.
+var decoders = map[string]decoder{
+	".csv":  decodeCSV,
+	".json": decodeJSON,
+	".yaml": decodeYAML,
+	".yml":  decodeYAML,
+}
+
+// tableDecoder decodes rows of the table from file with given extension.
+type tableDecoder[T any] func(ext, table string, r io.Reader) ([]map[string]T, error)
+
+// decodeValues decodes fixture values of the table.
+func decodeValues(ext, _ string, r io.Reader) ([]map[string]driver.Value, error) {
+	return decoders[ext](r)
+}
+
+// validatorDecoder decodes expected rows of tables. Cells of csv files are
+// typed by their header, values of json and yaml files are typed by columns of
+// the table from schemas.
+func validatorDecoder(schemas map[string]dbenv.TableSchema, keys func(table string) []string) tableDecoder[Validator] {
+	return func(ext, table string, r io.Reader) ([]map[string]Validator, error) {
+		if ext == ".csv" {
+			return readCSV(r, newValidator(keys(table)))
+		}
+
+		rows, err := decoders[ext](r)
+		if err != nil {
+			return nil, err
+		}
+
+		res := make([]map[string]Validator, len(rows))
+		for i, row := range rows {
+			res[i] = make(map[string]Validator, len(row))
+			for column, value := range row {
+				v, err := schemaValidator(schemas[table], keys(table), column, value)
+				if err != nil {
+					return nil, fmt.Errorf("row %v: column %#v: %w", i, column, err)
+				}
+				res[i][column] = v
+			}
+		}
+
+		return res, nil
+	}
+}
+
+// schemaValidator creates validator of the decoded value with type of the
+// column. Values of columns with unknown types are compared as is.
+func schemaValidator(schema dbenv.TableSchema, pkeys []string, column string, value driver.Value) (Validator, error) {
+	i := slices.IndexFunc(schema.Types, func(c dbenv.ColumnType) bool { return c.Name == column })
+	var typ string
+	if i >= 0 {
+		typ = schema.Types[i].Typ
+	}
+	t, known := LookupType(typ)
+
+	s, isString := value.(string)
+	switch {
+	case isString && strings.HasPrefix(s, "="):
+		if !known {
+			return nil, fmt.Errorf("type %#v not found", typ)
+		}
+		if schema.Types[i].Nullable {
+			typ = "?" + typ
+		}
+		return newValidator(pkeys)(column, typ, s)
+	case !known:
+		return constValidator{value: value}, nil
+	case value == nil:
+		return constValidator{value: nil, typ: t}, nil
+	}
+
+	switch v := value.(type) {
+	case map[string]any, []any:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		s = string(b)
+	case time.Time:
+		s = v.Format(time.RFC3339Nano)
+	default:
+		s = exprText(v)
+	}
+
+	return newValidator(pkeys)(column, typ, s)
+}
+
+func decodeCSV(r io.Reader) ([]map[string]driver.Value, error) { return readCSV(r, newValueParser()) }
+
+func decodeJSON(r io.Reader) (res []map[string]driver.Value, err error) {
+	d := json.NewDecoder(r)
+	d.UseNumber() // keeps bigint values precise
+	if err := d.Decode(&res); err != nil {
+		return nil, err
+	}
+
+	for _, row := range res {
+		for column, value := range row {
+			if n, ok := value.(json.Number); ok {
+				row[column] = jsonNumber(n)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// jsonNumber converts number to int64 or float64, like yaml decoder does.
+// Integers, which don't fit into int64, are kept as text, so they are parsed
+// by database without loss of precision.
+func jsonNumber(n json.Number) driver.Value {
+	if i, err := n.Int64(); err == nil {
+		return i
+	} else if !strings.ContainsAny(n.String(), ".eE") {
+		return n.String()
+	}
+
+	f, _ := n.Float64()
+	return f
+}
+
+func decodeYAML(r io.Reader) (res []map[string]driver.Value, err error) {
+	if err := yaml.NewDecoder(r).Decode(&res); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// readFixtures reads all tables from each directory. Every next directory
+// overrides tables from previous ones: table file replaces all rows of the
+// table, and file with "+" prefix (e.g. "+users.csv") appends rows to them.
+// Subdirectories are schemas, e.g. "billing/invoices.csv" is the table
+// "billing.invoices".
+func readFixtures[T any](fsys fs.FS, decode tableDecoder[T], dirs ...string) (map[string][]map[string]T, error) {
+	res := make(map[string][]map[string]T)
+	for _, dir := range dirs {
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		files := make(map[string]string, len(entries))
+		for _, entry := range entries {
+			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+
+			schemaEntries, err := fs.ReadDir(fsys, path.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			if err := readTables(fsys, decode, dir, entry.Name(), schemaEntries, files, res); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := readTables(fsys, decode, dir, "", entries, files, res); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// readTables reads table files of the single schema directory. files keeps
+// file of every table in the fixtures directory to find duplicates.
+func readTables[T any](fsys fs.FS, decode tableDecoder[T], dir, schema string, entries []fs.DirEntry, files map[string]string, res map[string][]map[string]T) error {
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		ext := path.Ext(name)
+		if _, ok := decoders[strings.ToLower(ext)]; !ok {
+			continue
+		}
+
+		tableName, appendRows := strings.CutPrefix(strings.TrimSuffix(name, ext), appendPrefix)
+		if schema != "" {
+			tableName = schema + "." + tableName
+			name = path.Join(schema, name)
+		}
+		if prev, ok := files[tableName]; ok {
+			return fmt.Errorf("table %#v: defined twice in %v: %v and %v", tableName, dir, prev, name)
+		}
+		files[tableName] = name
+
+		rows, err := decodeFile(fsys, path.Join(dir, name), func(r io.Reader) ([]map[string]T, error) {
+			return decode(strings.ToLower(ext), tableName, r)
+		})
+		if err != nil {
+			return fmt.Errorf("table %#v: %w", tableName, err)
+		}
+
+		if appendRows {
+			res[tableName] = append(res[tableName], rows...)
+		} else {
+			res[tableName] = rows
+		}
+	}
+
+	return nil
+}
+
+func decodeFile[T any](fsys fs.FS, name string, decode func(r io.Reader) ([]map[string]T, error)) ([]map[string]T, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+
+	return rows, nil
+}
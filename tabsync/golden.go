@@ -0,0 +1,418 @@
+package tabsync
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/quenbyako/ext/maps"
+	"github.com/quenbyako/ext/slices"
+	"gopkg.in/yaml.v3"
+
+	"github.com/quenbyako/sqltest/dbenv"
+)
+
+var updateFlag = flag.Bool("sqltest.update", false, "rewrite golden files of tabsync.ValidateGolden with actual state of the database")
+
+// updateEnv is environment variable, which works like -sqltest.update flag,
+// e.g. for packages, which don't accept flags in go test ./...
+const updateEnv = "SQLTEST_UPDATE"
+
+func updateGolden() bool {
+	update, _ := strconv.ParseBool(os.Getenv(updateEnv))
+	return *updateFlag || update
+}
+
+// maskedCell replaces values of masked columns in golden files.
+const maskedCell = "*"
+
+// maskedValue is a value of masked cell in goldenSnapshot, so it differs
+// from the text value "*".
+type maskedValue struct{}
+
+// goldenExts are supported formats of golden files in order of preference.
+// New golden files are always written as csv.
+var goldenExts = []string{".csv", ".yaml", ".yml", ".json"}
+
+// ValidateGolden compares tables of the container with golden files in dir,
+// e.g. "testdata/users.csv" (tables of non-default schemas are placed in
+// subdirectories, like fixtures of FlushFS). Without tables all tables of the
+// database are compared. Run tests with -sqltest.update flag (or
+// SQLTEST_UPDATE=1 environment variable) to write actual state of tables into
+// golden files.
+//
+// Volatile columns, e.g. timestamps or generated ids, can be masked with
+// validator expressions: in csv header as "created_at=value != nil", or in
+// "masks" mapping of yaml and json files. Masked cells are written as "*", and
+// compared with expression only.
+//
+// Null values are written as null in csv. Text values, which are the same as
+// special cells ("*", and "null" in csv), or start with backslash, are
+// escaped with backslash, e.g. "\null" or "\*".
+func ValidateGolden(container dbenv.Container, dir string, tables ...string) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+
+	dumped, err := container.Dump(ctx)
+	if err != nil {
+		return fmt.Errorf("can't fetch database schema %w", err)
+	}
+
+	if len(tables) == 0 {
+		tables = slices.Sort(maps.Keys(dumped))
+	}
+
+	var errs []error
+	for _, table := range tables {
+		data, ok := dumped[table]
+		if !ok {
+			errs = append(errs, fmt.Errorf("table %#v: not exists in database", table))
+			continue
+		}
+
+		if err := validateGolden(goldenPath(dir, table), data, updateGolden()); err != nil {
+			errs = append(errs, fmt.Errorf("table %#v: %w", table, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// goldenPath returns path of existing golden file of the table, or path of
+// the new csv file.
+func goldenPath(dir, table string) string {
+	base := filepath.Join(dir, filepath.FromSlash(strings.ReplaceAll(table, ".", "/")))
+	for _, ext := range goldenExts {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext
+		}
+	}
+
+	return base + goldenExts[0]
+}
+
+func validateGolden(path string, data dbenv.TableData, update bool) error {
+	want, err := os.ReadFile(path)
+	if err != nil && !(update && errors.Is(err, fs.ErrNotExist)) {
+		return fmt.Errorf("can't read golden file, run tests with -sqltest.update flag to create it: %w", err)
+	}
+
+	format := goldenFormats[filepath.Ext(path)]
+
+	var masks map[string]string
+	if len(want) > 0 {
+		if masks, err = format.masks(want); err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+	}
+
+	snapshot, err := newGoldenSnapshot(data, masks)
+	if err != nil {
+		return err
+	}
+
+	got, err := format.encode(snapshot)
+	if err != nil {
+		return fmt.Errorf("%v: %w", path, err)
+	}
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, got, 0o644)
+	}
+
+	if !bytes.Equal(want, got) {
+		return fmt.Errorf("%v: mismatched golden file (-want +got):\n%v", path, diffLines(string(want), string(got)))
+	}
+
+	return nil
+}
+
+// goldenSnapshot is a table prepared for golden file: columns are ordered,
+// and masked cells are replaced.
+type goldenSnapshot struct {
+	columns []string
+	masks   map[string]string
+	rows    [][]driver.Value
+}
+
+func newGoldenSnapshot(data dbenv.TableData, masks map[string]string) (goldenSnapshot, error) {
+	res := goldenSnapshot{columns: goldenColumns(data.Schema), masks: masks}
+	types := data.Schema.TypeMap()
+
+	progs := make(map[string]*vm.Program, len(masks))
+	for column, mask := range masks {
+		if !slices.Contains(res.columns, column) {
+			return res, fmt.Errorf("mask of column %#v: column not exists in table", column)
+		}
+
+		// value is unknown until rows are checked, so it's not typed.
+		e := newValidatorExprEnv(types[column], nil)
+		delete(e, "value")
+
+		prog, err := expr.Compile(mask, expr.Env(e), expr.AllowUndefinedVariables(), expr.AsBool())
+		if err != nil {
+			return res, fmt.Errorf("mask of column %#v: %w", column, err)
+		}
+		progs[column] = prog
+	}
+
+	var errs []error
+	for i, row := range data.Rows {
+		values := make([]driver.Value, len(res.columns))
+		for j, column := range res.columns {
+			values[j] = row[column]
+
+			prog, ok := progs[column]
+			if !ok {
+				continue
+			}
+
+			valid, err := expr.Run(prog, newValidatorExprEnv(types[column], row[column]))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("row %v: key %q: evaluating mask: %w", i, column, err))
+			} else if !valid.(bool) {
+				errs = append(errs, fmt.Errorf("row %v: key %q: on %#v, fails mask: %v", i, column, row[column], masks[column]))
+			}
+			values[j] = maskedValue{}
+		}
+		res.rows = append(res.rows, values)
+	}
+
+	// rows of tables without primary key are dumped in arbitrary order.
+	if len(data.Schema.PrimaryKeys) == 0 {
+		sort.SliceStable(res.rows, func(a, b int) bool {
+			for j, column := range res.columns {
+				if _, ok := progs[column]; ok {
+					continue // masked cells are the same
+				}
+				if v := cmpValue(true)(res.rows[a][j], res.rows[b][j]); v != 0 {
+					return v < 0
+				}
+			}
+			return false
+		})
+	}
+
+	return res, errors.Join(errs...)
+}
+
+// goldenColumns returns primary keys first, and other columns in order of
+// the table.
+func goldenColumns(schema dbenv.TableSchema) []string {
+	res := slices.Clone(schema.PrimaryKeys)
+	for _, column := range schema.Types {
+		if !slices.Contains(res, column.Name) {
+			res = append(res, column.Name)
+		}
+	}
+
+	return res
+}
+
+type goldenFormat struct {
+	masks  func(data []byte) (map[string]string, error)
+	encode func(s goldenSnapshot) ([]byte, error)
+}
+
+var goldenFormats = map[string]goldenFormat{
+	".csv":  {masks: csvMasks, encode: encodeGoldenCSV},
+	".yaml": {masks: yamlMasks, encode: encodeGoldenYAML},
+	".yml":  {masks: yamlMasks, encode: encodeGoldenYAML},
+	".json": {masks: jsonMasks, encode: encodeGoldenJSON},
+}
+
+// csvMasks reads masks from header, where masked column is declared as
+// "name=expression".
+func csvMasks(data []byte) (map[string]string, error) {
+	header, err := csv.NewReader(bytes.NewReader(data)).Read()
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+
+	masks := make(map[string]string)
+	for _, field := range header {
+		if name, mask, ok := strings.Cut(field, "="); ok {
+			masks[strings.TrimSpace(name)] = strings.TrimSpace(mask)
+		}
+	}
+
+	return masks, nil
+}
+
+func encodeGoldenCSV(s goldenSnapshot) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+
+	header := make([]string, len(s.columns))
+	for i, column := range s.columns {
+		header[i] = column
+		if mask, ok := s.masks[column]; ok {
+			header[i] += "=" + mask
+		}
+	}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, row := range s.rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			if v == nil {
+				record[i] = "null"
+			} else {
+				record[i] = fmt.Sprint(goldenValue(v, "null", maskedCell))
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// goldenDocument is a structure of yaml and json golden files.
+type goldenDocument struct {
+	Masks map[string]string `json:"masks,omitempty" yaml:"masks,omitempty"`
+	Rows  []map[string]any  `json:"rows" yaml:"rows"`
+}
+
+func newGoldenDocument(s goldenSnapshot) goldenDocument {
+	res := goldenDocument{Masks: s.masks, Rows: []map[string]any{}}
+	for _, row := range s.rows {
+		m := make(map[string]any, len(row))
+		for i, v := range row {
+			m[s.columns[i]] = goldenValue(v, maskedCell)
+		}
+		res.Rows = append(res.Rows, m)
+	}
+
+	return res
+}
+
+func yamlMasks(data []byte) (map[string]string, error) {
+	var doc goldenDocument
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	return doc.Masks, nil
+}
+
+func encodeGoldenYAML(s goldenSnapshot) ([]byte, error) {
+	var b bytes.Buffer
+	e := yaml.NewEncoder(&b)
+	e.SetIndent(2)
+	if err := e.Encode(newGoldenDocument(s)); err != nil {
+		return nil, err
+	}
+	if err := e.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func jsonMasks(data []byte) (map[string]string, error) {
+	var doc goldenDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	return doc.Masks, nil
+}
+
+func encodeGoldenJSON(s goldenSnapshot) ([]byte, error) {
+	b, err := json.MarshalIndent(newGoldenDocument(s), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// goldenValue converts value, so it's written the same way in every format.
+// Text values are escaped, if they are the same as special cells of the
+// format.
+func goldenValue(v driver.Value, special ...string) any {
+	switch v := v.(type) {
+	case maskedValue:
+		return maskedCell
+	case []byte:
+		return escapeGoldenText(string(v), special)
+	case string:
+		return escapeGoldenText(v, special)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return v
+	}
+}
+
+// escapeGoldenText prefixes text with backslash, if it's one of special cells
+// or starts with backslash itself.
+func escapeGoldenText(s string, special []string) string {
+	if strings.HasPrefix(s, `\`) || slices.Contains(special, s) {
+		return `\` + s
+	}
+
+	return s
+}
+
+// diffLines returns line by line difference of two texts, based on their
+// longest common subsequence.
+func diffLines(want, got string) string {
+	a := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+
+	// lcs[i][j] is the length of common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var res strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			res.WriteString("  " + a[i] + "\n")
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			res.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			res.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+
+	return strings.TrimSuffix(res.String(), "\n")
+}
@@ -0,0 +1,193 @@
+package tabsync_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/internal/fakedb"
+	"github.com/quenbyako/sqltest/tabsync"
+)
+
+func TestValidateGolden(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := &fakedb.Container{
+		Schema: map[string]dbenv.TableSchema{
+			"users": {PrimaryKeys: []string{"id"}, Types: []dbenv.ColumnType{
+				{Name: "id", Typ: "integer"},
+				{Name: "name", Typ: "text"},
+				{Name: "created_at", Typ: "timestamp with time zone"},
+			}},
+			"billing.tags": {Types: []dbenv.ColumnType{{Name: "tag", Typ: "text"}}},
+		},
+		Flushed: map[string][]dbenv.TableRow{
+			"users": {
+				{"id": int64(1), "name": "John", "created_at": created},
+				{"id": int64(2), "name": nil, "created_at": created},
+			},
+			"billing.tags": {{"tag": "b"}, {"tag": "a"}},
+		},
+	}
+
+	dir := t.TempDir()
+	require.ErrorContains(t, tabsync.ValidateGolden(c, dir), "run tests with -sqltest.update flag to create it")
+
+	setUpdate(t, true)
+	require.NoError(t, tabsync.ValidateGolden(c, dir))
+	setUpdate(t, false)
+	require.NoError(t, tabsync.ValidateGolden(c, dir))
+
+	requireFile(t, filepath.Join(dir, "users.csv"), "id,name,created_at\n1,John,2024-01-02T03:04:05Z\n2,null,2024-01-02T03:04:05Z\n")
+	requireFile(t, filepath.Join(dir, "billing", "tags.csv"), "tag\na\nb\n")
+
+	// masks are kept on update
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "users.csv"), []byte("id,name,created_at=value != nil\n"), 0o644))
+	setUpdate(t, true)
+	require.NoError(t, tabsync.ValidateGolden(c, dir, "users"))
+	setUpdate(t, false)
+	requireFile(t, filepath.Join(dir, "users.csv"), "id,name,created_at=value != nil\n1,John,*\n2,null,*\n")
+
+	c.Flushed["users"][0]["created_at"] = created.Add(time.Hour)
+	require.NoError(t, tabsync.ValidateGolden(c, dir, "users"))
+
+	c.Flushed["users"][1]["created_at"] = nil
+	c.Flushed["users"][0]["name"] = "Johnny"
+	require.EqualError(t, tabsync.ValidateGolden(c, dir, "users"),
+		`table "users": row 1: key "created_at": on <nil>, fails mask: value != nil`)
+
+	c.Flushed["users"][1]["created_at"] = created
+	require.EqualError(t, tabsync.ValidateGolden(c, dir, "users"),
+		`table "users": `+filepath.Join(dir, "users.csv")+": mismatched golden file (-want +got):\n"+
+			"  id,name,created_at=value != nil\n"+
+			"- 1,John,*\n"+
+			"+ 1,Johnny,*\n"+
+			"  2,null,*")
+}
+
+func TestValidateGoldenEscaping(t *testing.T) {
+	c := &fakedb.Container{
+		Schema: map[string]dbenv.TableSchema{
+			"notes": {PrimaryKeys: []string{"id"}, Types: []dbenv.ColumnType{
+				{Name: "id", Typ: "integer"},
+				{Name: "text", Typ: "text"},
+				{Name: "token", Typ: "text"},
+			}},
+		},
+		Flushed: map[string][]dbenv.TableRow{
+			"notes": {
+				{"id": int64(1), "text": nil, "token": "a"},
+				{"id": int64(2), "text": "null", "token": "b"},
+				{"id": int64(3), "text": "*", "token": "c"},
+				{"id": int64(4), "text": `\n`, "token": "d"},
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.csv"), []byte("id,text,token=value != nil\n"), 0o644))
+	setUpdate(t, true)
+	require.NoError(t, tabsync.ValidateGolden(c, dir))
+	setUpdate(t, false)
+
+	requireFile(t, filepath.Join(dir, "notes.csv"), "id,text,token=value != nil\n"+
+		"1,null,*\n"+
+		"2,\\null,*\n"+
+		"3,\\*,*\n"+
+		"4,\\\\n,*\n")
+
+	// literal values differ from null and masked cells.
+	c.Flushed["notes"][1]["text"] = nil
+	c.Flushed["notes"][2]["text"] = "x"
+	err := tabsync.ValidateGolden(c, dir)
+	for _, line := range []string{"- 2,\\null,*\n", "+ 2,null,*\n", "- 3,\\*,*\n", "+ 3,x,*\n"} {
+		require.ErrorContains(t, err, line)
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.json"), []byte(`{"masks": {"token": "value != nil"}}`), 0o644))
+	require.NoError(t, os.Remove(filepath.Join(dir, "notes.csv")))
+	c.Flushed["notes"][2]["text"] = "*"
+	setUpdate(t, true)
+	require.NoError(t, tabsync.ValidateGolden(c, dir))
+	setUpdate(t, false)
+
+	got, err := os.ReadFile(filepath.Join(dir, "notes.json"))
+	require.NoError(t, err)
+	require.Contains(t, string(got), `"text": "\\*"`)
+	require.Contains(t, string(got), `"text": null`)
+}
+
+func TestValidateGoldenFormats(t *testing.T) {
+	c := &fakedb.Container{
+		Schema: map[string]dbenv.TableSchema{
+			"users": {PrimaryKeys: []string{"id"}, Types: []dbenv.ColumnType{
+				{Name: "id", Typ: "integer"},
+				{Name: "token", Typ: "uuid"},
+			}},
+		},
+		Flushed: map[string][]dbenv.TableRow{
+			"users": {{"id": int64(1), "token": "0b7b3c3e-8d5c-4a39-9d6c-1f7a2b3c4d5e"}},
+		},
+	}
+
+	for _, tt := range []struct {
+		file   string
+		header string
+		want   string
+	}{{
+		file:   "users.yaml",
+		header: "masks:\n  token: len(value) == 36\n",
+		want:   "masks:\n  token: len(value) == 36\nrows:\n  - id: 1\n    token: '*'\n",
+	}, {
+		file:   "users.json",
+		header: `{"masks": {"token": "len(value) == 36"}}`,
+		want:   "{\n  \"masks\": {\n    \"token\": \"len(value) == 36\"\n  },\n  \"rows\": [\n    {\n      \"id\": 1,\n      \"token\": \"*\"\n    }\n  ]\n}\n",
+	}} {
+		t.Run(tt.file, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, tt.file), []byte(tt.header), 0o644))
+
+			setUpdate(t, true)
+			require.NoError(t, tabsync.ValidateGolden(c, dir))
+			setUpdate(t, false)
+
+			requireFile(t, filepath.Join(dir, tt.file), tt.want)
+			require.NoError(t, tabsync.ValidateGolden(c, dir))
+		})
+	}
+}
+
+func TestValidateGoldenUpdateEnv(t *testing.T) {
+	c := &fakedb.Container{
+		Schema:  map[string]dbenv.TableSchema{"tags": {Types: []dbenv.ColumnType{{Name: "tag", Typ: "text"}}}},
+		Flushed: map[string][]dbenv.TableRow{"tags": {{"tag": "a"}}},
+	}
+
+	dir := t.TempDir()
+	t.Setenv("SQLTEST_UPDATE", "1")
+	require.NoError(t, tabsync.ValidateGolden(c, dir))
+	requireFile(t, filepath.Join(dir, "tags.csv"), "tag\na\n")
+}
+
+func setUpdate(t *testing.T, update bool) {
+	t.Helper()
+
+	value := "false"
+	if update {
+		value = "true"
+	}
+	require.NoError(t, flag.Set("sqltest.update", value))
+	t.Cleanup(func() { flag.Set("sqltest.update", "false") })
+}
+
+func requireFile(t *testing.T, path, want string) {
+	t.Helper()
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, want, string(got))
+}
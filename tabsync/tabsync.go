@@ -2,39 +2,189 @@ package tabsync
 
 import (
 	"context"
-	"database/sql"
 	"database/sql/driver"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"path"
+	"strings"
 	"time"
 
-	"github.com/quenbyako/sqltest"
+	"github.com/quenbyako/ext/maps"
+	"github.com/quenbyako/ext/slices"
+
+	"github.com/quenbyako/sqltest/dbenv"
 )
 
+// FlushFS works like FlushRaw, but reads tables from directory, where each
+// file is a single table, e.g. "users.csv", "orders.yaml" or "audit.json".
+// Format of the table is selected by file extension. Overlay directories are
+// applied over the base one in order: their files replace tables from previous
+// directories, or append rows to them, if file name is prefixed with "+", e.g.
+// "+users.csv". Tables of non-default schemas are placed in subdirectories,
+// e.g. "billing/invoices.csv". Nested objects and arrays of json and yaml
+// files are allowed only for json columns.
+func FlushFS(container dbenv.Container, fsys fs.FS, path string, overlays ...string) error {
+	data, err := readFixtures(fsys, decodeValues, append([]string{path}, overlays...)...)
+	if err != nil {
+		return fmt.Errorf("can't read fixtures %w", err)
+	}
+
+	return FlushRaw(container, data)
+}
+
+// FlushCSV works like FlushRaw, but reads every table from csv file. First
+// row of each file is a header, which can declare column types in format
+// "name:type", e.g. "id:int,name:text,parent_id:?int" (see LookupType for
+// supported types, and RegisterType for custom ones). Nullable types (with "?"
+// prefix) accepts "null" literal, and cells started with "=" are evaluated as
+// expressions.
+func FlushCSV(container dbenv.Container, data map[string]io.Reader) error {
+	tables := make(map[string][]map[string]driver.Value, len(data))
+	for tableName, r := range data {
+		rows, err := readCSV(r, newValueParser())
+		if err != nil {
+			return fmt.Errorf("table %#v: %w", tableName, err)
+		}
+
+		tables[tableName] = rows
+	}
+
+	return FlushRaw(container, tables)
+}
+
+// FlushRaw replaces all data in container with provided rows. Values are
+// coerced to column types of the table, and tables are filled in order of
+// their foreign keys, so referenced rows are always inserted first.
+func FlushRaw(container dbenv.Container, data map[string][]map[string]driver.Value) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+
+	tables := make(map[string][]dbenv.TableRow, len(data))
+	for tableName, rows := range data {
+		tables[tableName] = slices.Remap(rows, func(row map[string]driver.Value) dbenv.TableRow { return row })
+	}
+
+	if err := container.Flush(ctx, tables); err != nil {
+		return fmt.Errorf("can't flush data %w", err)
+	}
+
+	return nil
+}
+
+// ValidateTableFS works like ValidateTableRaw, but reads expected rows from
+// directory in the same layout as FlushFS does. Cells of csv files are typed
+// by their header, values of json and yaml files are parsed with types of the
+// table columns. Cells and strings, started with "=", are validator
+// expressions.
+func ValidateTableFS(container dbenv.Container, fsys fs.FS, path string, opts ...TableOption) error {
+	return validateTablesWith(container, opts, func(d tableDecoder[Validator]) (map[string][]map[string]Validator, error) {
+		return readFixtures(fsys, d, path)
+	})
+}
+
+// ValidateTableCSV works like ValidateTableRaw, but reads expected rows of
+// every table from csv file, in the same format as FlushCSV does. Cells
+// started with "=" are validator expressions.
+func ValidateTableCSV(container dbenv.Container, data map[string]io.Reader, opts ...TableOption) error {
+	return validateTablesWith(container, opts, func(d tableDecoder[Validator]) (map[string][]map[string]Validator, error) {
+		validators := make(map[string][]map[string]Validator, len(data))
+		for tableName, r := range data {
+			rows, err := d(".csv", tableName, r)
+			if err != nil {
+				return nil, fmt.Errorf("table %#v: %w", tableName, err)
+			}
+			validators[tableName] = rows
+		}
+
+		return validators, nil
+	})
+}
+
+// validateTablesWith reads validators with the schema of the container, and
+// validates its tables.
+func validateTablesWith(container dbenv.Container, opts []TableOption, read func(tableDecoder[Validator]) (map[string][]map[string]Validator, error)) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+
+	cfg := newTableConfig(opts)
+
+	dumped, err := container.Dump(ctx)
+	if err != nil {
+		return fmt.Errorf("can't fetch database schema %w", err)
+	}
+
+	schemas := make(map[string]dbenv.TableSchema, len(dumped))
+	for tableName, data := range dumped {
+		schemas[tableName] = data.Schema
+	}
+
+	validators, err := read(validatorDecoder(schemas, func(table string) []string {
+		if keys, ok := cfg.keys[table]; ok {
+			return keys
+		}
+		return schemas[table].PrimaryKeys
+	}))
+	if err != nil {
+		return fmt.Errorf("can't read expected tables %w", err)
+	}
 
-func FlushFS(container sqltest.Container, fsys fs.FS, path string) error {
-	panic("Unimplemented")
+	return validateTables(dumped, validators, cfg)
 }
 
-func FlushCSV(container sqltest.Container, data map[string]io.Reader) error {
-	panic("Unimplemented")
+// TableOption configures table validation.
+type TableOption func(*tableConfig)
+
+type tableConfig struct {
+	strict    bool
+	strictFor []string
+	keys      map[string][]string
 }
 
-func FlushRaw(container sqltest.Container, data map[string][]map[string]driver.Value) error {
-	panic("Unimplemented")
+// Strict reports rows, which exist in the table, but are not expected, so
+// table must contain exactly expected rows. Without arguments it applies to
+// all validated tables, otherwise only to listed ones.
+func Strict(tables ...string) TableOption {
+	return func(c *tableConfig) {
+		if len(tables) == 0 {
+			c.strict = true
+		}
+		c.strictFor = append(c.strictFor, tables...)
+	}
 }
 
-func ValidateTableFS(container sqltest.Container, fsys fs.FS, path string) error {
-	panic("Unimplemented")
+// MatchBy matches expected rows with rows of the table by given columns
+// instead of primary key, e.g. for tables without primary key. Values of the
+// columns must be unique. Rows of tables without primary key and declared
+// columns are matched by all their validators.
+func MatchBy(table string, columns ...string) TableOption {
+	return func(c *tableConfig) {
+		if c.keys == nil {
+			c.keys = make(map[string][]string)
+		}
+		c.keys[table] = columns
+	}
 }
 
-func ValidateTableCSV(container sqltest.Container, data map[string]io.Reader) error {
-	panic("Unimplemented")
+func newTableConfig(opts []TableOption) tableConfig {
+	var c tableConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
 }
 
-func ValidateTableRaw(container sqltest.Container, validators map[string][]map[string]Validator) error {
+func (c tableConfig) isStrict(table string) bool {
+	return c.strict || slices.Contains(c.strictFor, table)
+}
+
+// ValidateTableRaw checks, that tables contain expected rows, which are
+// matched by primary key (see MatchBy for tables without it). Rows, which are
+// marked with NotExists, must be absent. Other rows of tables are ignored,
+// unless Strict option is set.
+func ValidateTableRaw(container dbenv.Container, validators map[string][]map[string]Validator, opts ...TableOption) error {
 	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
 	defer cancel()
 
@@ -43,30 +193,179 @@ func ValidateTableRaw(container sqltest.Container, validators map[string][]map[s
 		return fmt.Errorf("can't fetch database schema %w", err)
 	}
 
-	var errs []error
-	for tableName, validators := range validators {
+	return validateTables(dumped, validators, newTableConfig(opts))
+}
+
+func validateTables(dumped map[string]dbenv.TableData, validators map[string][]map[string]Validator, cfg tableConfig) error {
+	var res ValidationError
+	for _, tableName := range slices.Sort(maps.Keys(validators)) {
 		data, ok := dumped[tableName]
 		if !ok {
-			errs = append(errs, fmt.Errorf("table %#v: not exists in database", tableName))
+			res.Tables = append(res.Tables, TableMismatch{Table: tableName, Errors: []error{errors.New("not exists in database")}})
+			continue
+		}
+
+		if keys, ok := cfg.keys[tableName]; ok {
+			data.Schema.PrimaryKeys = keys
 		}
 
-		if err := validateTable(data, validators); err != nil {
-			errs = append(errs, err)
+		if t := matchTable(data, validators[tableName], cfg.isStrict(tableName)); !t.empty() {
+			t.Table = tableName
+			res.Tables = append(res.Tables, t)
 		}
 	}
 
-	return errors.Join(errs...)
+	return res.err()
 }
 
+// TakeBaseline saves current state of the container, so ValidateChangesRaw can
+// check later, what exactly was changed by the test.
+func TakeBaseline(container dbenv.Container) (*dbenv.Baseline, error) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+
+	baseline, err := dbenv.NewBaseline(ctx, container)
+	if err != nil {
+		return nil, fmt.Errorf("can't dump database %w", err)
+	}
+
+	return baseline, nil
+}
+
+// Changes are expected modifications of single table. Expected rows are
+// matched with changed ones by primary key, like ValidateTableRaw does.
+type Changes struct {
+	// Inserted are validators of new rows.
+	Inserted []map[string]Validator
+	// Updated are validators of updated rows, as they are after update.
+	Updated []map[string]Validator
+	// Deleted are validators of deleted rows, usually only primary keys.
+	Deleted []map[string]Validator
+}
+
+// ValidateChangesRaw checks, that database is changed since baseline exactly
+// as expected. Any change, which is not expected, including changes of tables,
+// which are not listed, is an error.
+func ValidateChangesRaw(container dbenv.Container, baseline *dbenv.Baseline, want map[string]Changes) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+
+	diff, err := baseline.Diff(ctx, container)
+	if err != nil {
+		return fmt.Errorf("can't dump database %w", err)
+	}
+
+	names := maps.Keys(want)
+	for name := range diff {
+		if _, ok := want[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	var res ValidationError
+	for _, name := range slices.Sort(names) {
+		got, ok := diff[name]
+		if !ok {
+			table, ok := baseline.Tables()[name]
+			if !ok {
+				res.Tables = append(res.Tables, TableMismatch{Table: name, Errors: []error{errors.New("not exists in database")}})
+				continue
+			}
+			got.Schema = table.Schema
+		}
+
+		changes := want[name]
+		for _, part := range []struct {
+			name string
+			got  []dbenv.TableRow
+			want []map[string]Validator
+		}{
+			{name: "inserted", got: got.Inserted, want: changes.Inserted},
+			{name: "updated", got: slices.Remap(got.Updated, func(c dbenv.RowChange) dbenv.TableRow { return c.After }), want: changes.Updated},
+			{name: "deleted", got: got.Deleted, want: changes.Deleted},
+		} {
+			if t := matchChanged(got.Schema, part.got, part.want); !t.empty() {
+				t.Table, t.Section = name, part.name
+				res.Tables = append(res.Tables, t)
+			}
+		}
+	}
+
+	return res.err()
+}
+
+// ResultOption configures how query result is compared with expected rows.
+type ResultOption func(*resultConfig)
 
-func ValidateResultFS(container sqltest.Container, fsys fs.FS, path string) error {
-	panic("Unimplemented")
+type resultConfig struct {
+	keys []string
 }
 
-func ValidateResultCSV(container sqltest.Container, data map[string]io.Reader) error {
-	panic("Unimplemented")
+// ByKeys matches expected rows with rows of the query result by values of key
+// columns, so order of rows doesn't matter. Without this option rows are
+// compared one by one in order, defined by query (e.g. by its ORDER BY clause).
+func ByKeys(columns ...string) ResultOption {
+	return func(c *resultConfig) { c.keys = columns }
 }
 
-func ValidateResultRaw(container sqltest.Container, validators map[string][]map[string]Validator) error {
-	panic("Unimplemented")
+func newResultConfig(opts []ResultOption) resultConfig {
+	var c resultConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// ValidateResultFS works like ValidateResultCSV, but reads query from file
+// (e.g. "queries/report.sql"), and expected result from csv file with the same
+// name near it ("queries/report.csv").
+func ValidateResultFS(container dbenv.Container, fsys fs.FS, queryFile string, opts ...ResultOption) error {
+	query, err := fs.ReadFile(fsys, queryFile)
+	if err != nil {
+		return fmt.Errorf("can't read query %w", err)
+	}
+
+	f, err := fsys.Open(strings.TrimSuffix(queryFile, path.Ext(queryFile)) + ".csv")
+	if err != nil {
+		return fmt.Errorf("can't read expected result %w", err)
+	}
+	defer f.Close()
+
+	return ValidateResultCSV(container, string(query), f, opts...)
+}
+
+// ValidateResultCSV works like ValidateResultRaw, but reads expected rows from
+// csv file, in the same format as FlushCSV does. Cells started with "=" are
+// validator expressions.
+func ValidateResultCSV(container dbenv.Container, query string, data io.Reader, opts ...ResultOption) error {
+	validators, err := readCSV(data, newValidator(newResultConfig(opts).keys))
+	if err != nil {
+		return fmt.Errorf("can't read expected result %w", err)
+	}
+
+	return ValidateResultRaw(container, query, validators, opts...)
+}
+
+// ValidateResultRaw runs query in container and validates its result with
+// validators.
+func ValidateResultRaw(container dbenv.Container, query string, validators []map[string]Validator, opts ...ResultOption) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+
+	cfg := newResultConfig(opts)
+
+	rows, err := container.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("can't run query %w", err)
+	}
+
+	if len(cfg.keys) == 0 {
+		return validateOrdered(rows, validators)
+	}
+
+	return validateTable(dbenv.TableData{
+		Schema: dbenv.TableSchema{PrimaryKeys: cfg.keys},
+		Rows:   rows,
+	}, validators, false)
 }
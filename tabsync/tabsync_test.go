@@ -1,3 +1,347 @@
 package tabsync_test
 
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
 
+	"github.com/stretchr/testify/require"
+
+	"github.com/quenbyako/sqltest/dbenv"
+	"github.com/quenbyako/sqltest/internal/fakedb"
+	"github.com/quenbyako/sqltest/tabsync"
+)
+
+// eq is the simplest constant validator.
+type eq struct{ value driver.Value }
+
+func (v eq) Validate(got driver.Value) error {
+	if !reflect.DeepEqual(got, v.value) {
+		return fmt.Errorf("got %#v, want %#v", got, v.value)
+	}
+	return nil
+}
+
+func (v eq) AsValue() (driver.Value, bool) { return v.value, true }
+
+func TestFlushCSV(t *testing.T) {
+	c := &fakedb.Container{}
+
+	err := tabsync.FlushCSV(c, map[string]io.Reader{
+		"groups": strings.NewReader("id:int,name:text\n" +
+			"1,Admins\n" +
+			"2,Users\n"),
+		"names": strings.NewReader("id:int,name,group_id:?int\n" +
+			"1,John,=1+1\n" +
+			"2,\"Doe, Jane\",null\n"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string][]dbenv.TableRow{
+		"groups": {
+			{"id": int64(1), "name": "Admins"},
+			{"id": int64(2), "name": "Users"},
+		},
+		"names": {
+			{"id": int64(1), "name": "John", "group_id": int64(2)},
+			{"id": int64(2), "name": "Doe, Jane", "group_id": nil},
+		},
+	}, c.Flushed)
+}
+
+func TestFlushCSVErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		data    string
+		wantErr string
+	}{{
+		name:    "Empty file",
+		data:    "",
+		wantErr: `table "t": header is missing`,
+	}, {
+		name:    "Null in not nullable column",
+		data:    "id:int\nnull\n",
+		wantErr: `table "t": line 2: column "id": invalid 32-bit integer value "null"`,
+	}, {
+		name:    "Unknown type",
+		data:    "id:unknown\n1\n",
+		wantErr: `table "t": line 2: column "id": type "unknown" not found`,
+	}, {
+		name:    "Duplicated column",
+		data:    "id:int,id:text\n",
+		wantErr: `table "t": header: column "id": declared twice`,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tabsync.FlushCSV(&fakedb.Container{}, map[string]io.Reader{"t": strings.NewReader(tt.data)})
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestFlushFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"base/groups.csv":  {Data: []byte("id:int,name\n1,Admins\n2,Users\n")},
+		"base/names.yaml":  {Data: []byte("- {id: 1, name: John, group_id: 1}\n")},
+		"base/audit.json":  {Data: []byte(`[{"id": 1, "action": "login"}]`)},
+		"base/README.md":   {Data: []byte("fixtures for tests")},
+		"test/groups.json": {Data: []byte(`[{"id": 3, "name": "Guests"}]`)},
+		"test/+names.csv":  {Data: []byte("id:int,name,group_id:?int\n2,Jane,null\n")},
+		// the same table name in other schema
+		"test/billing/names.csv": {Data: []byte("id:int\n5\n")},
+	}
+
+	c := &fakedb.Container{}
+	require.NoError(t, tabsync.FlushFS(c, fsys, "base", "test"))
+	require.Equal(t, map[string][]dbenv.TableRow{
+		"audit": {
+			{"id": int64(1), "action": "login"},
+		},
+		"groups": {
+			{"id": int64(3), "name": "Guests"},
+		},
+		"names": {
+			{"id": 1, "name": "John", "group_id": 1},
+			{"id": int64(2), "name": "Jane", "group_id": nil},
+		},
+		"billing.names": {
+			{"id": int64(5)},
+		},
+	}, c.Flushed)
+
+	// json numbers are decoded like yaml ones, and nested values are kept
+	// for json columns.
+	require.NoError(t, tabsync.FlushFS(c, fstest.MapFS{
+		"base/orders.json": {Data: []byte(`[{"id": 1, "price": 9.99, "serial": 12345678901234567890, "meta": {"tags": ["a"]}}]`)},
+	}, "base"))
+	require.Equal(t, map[string][]dbenv.TableRow{
+		"orders": {
+			{"id": int64(1), "price": 9.99, "serial": "12345678901234567890", "meta": map[string]any{"tags": []any{"a"}}},
+		},
+	}, c.Flushed)
+
+	err := tabsync.FlushFS(c, fstest.MapFS{
+		"base/names.csv":  {Data: []byte("id:int\n")},
+		"base/names.json": {Data: []byte("[]")},
+	}, "base")
+	require.EqualError(t, err, `can't read fixtures table "names": defined twice in base: names.csv and names.json`)
+
+	err = tabsync.FlushFS(c, fstest.MapFS{
+		"base/billing.names.csv": {Data: []byte("id:int\n")},
+		"base/billing/names.csv": {Data: []byte("id:int\n")},
+	}, "base")
+	require.EqualError(t, err, `can't read fixtures table "billing.names": defined twice in base: billing/names.csv and billing.names.csv`)
+}
+
+func TestValidateResult(t *testing.T) {
+	const query = "SELECT id, name, score FROM names ORDER BY score DESC"
+
+	c := &fakedb.Container{Results: map[string][]dbenv.TableRow{
+		query: {
+			{"id": int64(2), "name": "Jane", "score": int64(20)},
+			{"id": int64(1), "name": "John", "score": int64(10)},
+		},
+		"SELECT id, note FROM names": {
+			{"id": int64(1), "note": ""},
+		},
+	}}
+
+	fsys := fstest.MapFS{
+		"report.sql": {Data: []byte(query)},
+		"report.csv": {Data: []byte("id:int,name,score:int\n2,Jane,=value > 15\n1,John,10\n")},
+	}
+	require.NoError(t, tabsync.ValidateResultFS(c, fsys, "report.sql"))
+
+	// empty cells are plain values, not expressions.
+	err := tabsync.ValidateResultCSV(c, "SELECT id, note FROM names", strings.NewReader("id:int,note\n1,\n"))
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name    string
+		data    string
+		opts    []tabsync.ResultOption
+		wantErr string
+	}{{
+		name: "Ordered",
+		data: "id:int,name\n2,Jane\n1,John\n",
+	}, {
+		name: "Ordered with wrong order",
+		data: "id:int,name\n1,John\n2,Jane\n",
+		wantErr: `row 0: key "id": mismatched values: got 2, want 1` + "\n" +
+			`row 0: key "name": mismatched values: got "Jane", want "John"` + "\n" +
+			`row 1: key "id": mismatched values: got 1, want 2` + "\n" +
+			`row 1: key "name": mismatched values: got "John", want "Jane"`,
+	}, {
+		name: "Keyed with any order",
+		data: "id:int,name\n1,John\n2,Jane\n",
+		opts: []tabsync.ResultOption{tabsync.ByKeys("id")},
+	}, {
+		name:    "Keyed with missing row",
+		data:    "id:int,name\n3,Bob\n",
+		opts:    []tabsync.ResultOption{tabsync.ByKeys("id")},
+		wantErr: `row map[string]driver.Value{"id":3}: not found in database`,
+	}, {
+		name:    "Less rows than expected",
+		data:    "id:int\n2\n1\n3\n",
+		wantErr: `mismatched rows count: got 2, want 3`,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tabsync.ValidateResultCSV(c, query, strings.NewReader(tt.data), tt.opts...)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateChanges(t *testing.T) {
+	c := &fakedb.Container{
+		Schema: map[string]dbenv.TableSchema{
+			"users":  {PrimaryKeys: []string{"id"}},
+			"groups": {PrimaryKeys: []string{"id"}},
+		},
+		Flushed: map[string][]dbenv.TableRow{
+			"users": {
+				{"id": int64(1), "name": "John"},
+				{"id": int64(2), "name": "Jane"},
+			},
+			"groups": {{"id": int64(1), "name": "Admins"}},
+		},
+	}
+
+	baseline, err := tabsync.TakeBaseline(c)
+	require.NoError(t, err)
+
+	c.Flushed = map[string][]dbenv.TableRow{
+		"users": {
+			{"id": int64(1), "name": "Johnny"},
+			{"id": int64(3), "name": "Bob"},
+		},
+		"groups": {{"id": int64(1), "name": "Users"}},
+	}
+
+	err = tabsync.ValidateChangesRaw(c, baseline, map[string]tabsync.Changes{
+		"users": {
+			Inserted: []map[string]tabsync.Validator{{"id": eq{int64(3)}, "name": eq{"Bob"}}},
+			Updated:  []map[string]tabsync.Validator{{"id": eq{int64(1)}, "name": eq{"Johnny"}}},
+			Deleted:  []map[string]tabsync.Validator{{"id": eq{int64(2)}}},
+		},
+		"groups": {
+			Updated: []map[string]tabsync.Validator{{"id": eq{int64(1)}}},
+		},
+	})
+	require.NoError(t, err)
+
+	err = tabsync.ValidateChangesRaw(c, baseline, map[string]tabsync.Changes{
+		"users": {
+			Inserted: []map[string]tabsync.Validator{{"id": eq{int64(4)}}},
+			Updated:  []map[string]tabsync.Validator{{"id": eq{int64(1)}, "name": eq{"John"}}},
+		},
+	})
+	require.EqualError(t, err, strings.Join([]string{
+		`table "groups": updated rows: row map[string]driver.Value{"id":1}: not expected`,
+		`table "users": inserted rows: row map[string]driver.Value{"id":4}: not found in database`,
+		`table "users": inserted rows: row map[string]driver.Value{"id":3}: not expected`,
+		`table "users": updated rows: row map[string]driver.Value{"id":1}: key "name": got "Johnny", want "John"`,
+		`table "users": deleted rows: row map[string]driver.Value{"id":2}: not expected`,
+	}, "\n"))
+}
+
+func TestValidateTableFS(t *testing.T) {
+	c := &fakedb.Container{
+		Schema: map[string]dbenv.TableSchema{
+			"users": {PrimaryKeys: []string{"id"}, Types: []dbenv.ColumnType{
+				{Name: "id", Typ: "integer"},
+				{Name: "name", Typ: "text", Nullable: true},
+			}},
+			"orders": {PrimaryKeys: []string{"id"}, Types: []dbenv.ColumnType{
+				{Name: "id", Typ: "bigint"},
+				{Name: "price", Typ: "numeric"},
+				{Name: "meta", Typ: "jsonb"},
+			}},
+			"billing.invoices": {PrimaryKeys: []string{"id"}, Types: []dbenv.ColumnType{
+				{Name: "id", Typ: "integer"},
+				{Name: "total", Typ: "integer"},
+			}},
+		},
+		Flushed: map[string][]dbenv.TableRow{
+			"users":            {{"id": int64(1), "name": "John"}, {"id": int64(2), "name": nil}},
+			"orders":           {{"id": int64(1), "price": "9.990", "meta": []byte(`{"tags": ["a"]}`)}},
+			"billing.invoices": {{"id": int64(1), "total": int64(100)}},
+		},
+	}
+
+	fsys := fstest.MapFS{
+		"want/users.csv":             {Data: []byte("id:int,name:?text\n1,John\n2,null\n")},
+		"want/orders.yaml":           {Data: []byte("- id: 1\n  price: 9.99\n  meta: {tags: [a]}\n")},
+		"want/billing/invoices.json": {Data: []byte(`[{"id": 1, "total": "=value > 50"}]`)},
+	}
+	require.NoError(t, tabsync.ValidateTableFS(c, fsys, "want"))
+
+	fsys["want/billing/invoices.json"] = &fstest.MapFile{Data: []byte(`[{"id": 1, "total": 50}]`)}
+	require.EqualError(t, tabsync.ValidateTableFS(c, fsys, "want"),
+		`table "billing.invoices": row map[string]driver.Value{"id":1}: key "total": mismatched values: got 100, want 50`)
+
+	fsys["want/billing/invoices.json"] = &fstest.MapFile{Data: []byte(`[{"id": "=value > 0"}]`)}
+	require.ErrorContains(t, tabsync.ValidateTableFS(c, fsys, "want"), `primary keys can't be formulas`)
+
+	err := tabsync.ValidateTableCSV(c, map[string]io.Reader{
+		"users": strings.NewReader("id:int,name\n" + `1,"=value startsWith ""J"""` + "\n2,Jane\n"),
+	}, tabsync.Strict())
+	require.EqualError(t, err, `table "users": row map[string]driver.Value{"id":2}: key "name": mismatched values: got <nil>, want "Jane"`)
+}
+
+func TestValidateTableStrict(t *testing.T) {
+	c := &fakedb.Container{
+		Schema: map[string]dbenv.TableSchema{
+			"users":  {PrimaryKeys: []string{"id"}},
+			"groups": {PrimaryKeys: []string{"id"}},
+		},
+		Flushed: map[string][]dbenv.TableRow{
+			"users":  {{"id": int64(1), "name": "John"}, {"id": int64(2), "name": "Jane"}},
+			"groups": {{"id": int64(1)}, {"id": int64(2)}},
+		},
+	}
+	want := map[string][]map[string]tabsync.Validator{
+		"users": {
+			{"id": eq{int64(1)}, "name": eq{"John"}},
+			tabsync.NotExists(map[string]tabsync.Validator{"id": eq{int64(3)}}),
+		},
+		"groups": {{"id": eq{int64(1)}}},
+	}
+
+	for _, tt := range []struct {
+		name    string
+		opts    []tabsync.TableOption
+		wantErr string
+	}{{
+		name: "Not strict",
+	}, {
+		name: "Strict",
+		opts: []tabsync.TableOption{tabsync.Strict()},
+		wantErr: `table "groups": row map[string]driver.Value{"id":2}: not expected` + "\n" +
+			`table "users": row map[string]driver.Value{"id":2}: not expected`,
+	}, {
+		name:    "Strict table",
+		opts:    []tabsync.TableOption{tabsync.Strict("groups")},
+		wantErr: `table "groups": row map[string]driver.Value{"id":2}: not expected`,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tabsync.ValidateTableRaw(c, want, tt.opts...)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	err := tabsync.ValidateTableRaw(c, map[string][]map[string]tabsync.Validator{
+		"users": {tabsync.NotExists(map[string]tabsync.Validator{"id": eq{int64(2)}})},
+	})
+	require.EqualError(t, err, `table "users": row map[string]driver.Value{"id":2}: exists in database, but must not`)
+}
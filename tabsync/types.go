@@ -0,0 +1,809 @@
+package tabsync
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type describes, how values of a column type are read from fixtures and
+// compared with values, returned by database driver. Both Parse and Convert
+// must return values in the same canonical form, e.g. uuid is always a
+// lowercase string, and timestamp is always time.Time in UTC.
+type Type struct {
+	// Parse converts text of a fixture cell into value of the type. The value
+	// is inserted into database as is, so it must be accepted by driver.
+	Parse func(s string) (driver.Value, error)
+	// Convert brings value, returned by database driver, to the form, which
+	// Parse returns. Values are used as is, if Convert is nil.
+	Convert func(v driver.Value) (driver.Value, error)
+	// Equal compares two converted values. reflect.DeepEqual is used, if
+	// Equal is nil.
+	Equal func(a, b driver.Value) bool
+	// Zero is a sample value of the type, which is used to check validator
+	// expressions before running them, and types of converted values. Types
+	// of values are not checked, if Zero is nil.
+	Zero driver.Value
+}
+
+func (t Type) convert(v driver.Value) (driver.Value, error) {
+	if v == nil || t.Convert == nil {
+		return v, nil
+	}
+
+	return t.Convert(v)
+}
+
+func (t Type) equal(a, b driver.Value) bool {
+	if t.Equal == nil || a == nil || b == nil {
+		return reflect.DeepEqual(a, b)
+	}
+
+	return t.Equal(a, b)
+}
+
+var (
+	typesMu sync.RWMutex
+	types   = map[string]Type{}
+)
+
+func init() {
+	for _, t := range []struct {
+		names []string
+		typ   Type
+	}{
+		{names: []string{"text", "varchar", "character varying", "char", "character", "bpchar", "name", "citext"}, typ: textType},
+		{names: []string{"uuid"}, typ: uuidType},
+		{names: []string{"boolean", "bool"}, typ: boolType},
+		{names: []string{"smallint", "int2", "smallserial", "serial2"}, typ: intType(16)},
+		{names: []string{"integer", "int", "int4", "serial", "serial4"}, typ: intType(32)},
+		{names: []string{"bigint", "int8", "bigserial", "serial8"}, typ: intType(64)},
+		{names: []string{"real", "float4"}, typ: floatType(32)},
+		{names: []string{"double precision", "double", "float", "float8"}, typ: floatType(64)},
+		{names: []string{"numeric", "decimal"}, typ: numericType},
+		{names: []string{"date"}, typ: dateType},
+		{names: []string{"time", "time without time zone"}, typ: timeOfDayType},
+		{names: []string{"timetz", "time with time zone"}, typ: timeOfDayTZType},
+		{names: []string{
+			"timestamp", "timestamp without time zone", "datetime",
+			"timestamptz", "timestamp with time zone",
+		}, typ: timestampType},
+		{names: []string{"interval"}, typ: intervalType},
+		{names: []string{"bytea", "blob"}, typ: bytesType},
+		{names: []string{"json", "jsonb"}, typ: jsonType},
+		{names: []string{"inet", "cidr"}, typ: inetType},
+	} {
+		for _, name := range t.names {
+			types[name] = t.typ
+		}
+	}
+}
+
+// RegisterType adds column type, which can be used in fixtures, e.g. in csv
+// header as "status:mood", or replaces existing one. Enums and domains are
+// usually registered as aliases of their base types:
+//
+//	tabsync.RegisterType("mood", tabsync.Enum("sad", "ok", "happy"))
+//	tabsync.RegisterType("email", tabsync.MustLookupType("text"))
+func RegisterType(name string, t Type) {
+	if t.Parse == nil {
+		panic(fmt.Sprintf("type %#v: Parse function is required", name))
+	}
+
+	typesMu.Lock()
+	defer typesMu.Unlock()
+
+	types[normalizeTypeName(name)] = t
+}
+
+// LookupType returns registered type by its name. Builtin types are text,
+// uuid, boolean, smallint, integer, bigint, real, double precision, numeric,
+// date, time, timetz, timestamp, timestamptz, interval, bytea, json, jsonb, inet and
+// cidr, with their common aliases, e.g. "int" or "varchar". Names are case
+// insensitive, and type modifiers are ignored, so "VARCHAR(255)" is the same
+// type as "varchar". Array types, like "int[]" or "_int4", are derived from
+// types of their elements.
+func LookupType(name string) (Type, bool) {
+	name = normalizeTypeName(name)
+
+	typesMu.RLock()
+	t, ok := types[name]
+	typesMu.RUnlock()
+	if ok {
+		return t, true
+	}
+
+	if elem, ok := strings.CutSuffix(name, "[]"); ok {
+		if t, ok := LookupType(elem); ok {
+			return arrayType(t), true
+		}
+	} else if elem, ok := strings.CutPrefix(name, "_"); ok {
+		if t, ok := LookupType(elem); ok {
+			return arrayType(t), true
+		}
+	}
+
+	return Type{}, false
+}
+
+// MustLookupType works like LookupType, but panics, if type is not registered.
+func MustLookupType(name string) Type {
+	t, ok := LookupType(name)
+	if !ok {
+		panic(fmt.Sprintf("type %#v not found", name))
+	}
+
+	return t
+}
+
+// Enum returns text type, which accepts only listed values.
+func Enum(values ...string) Type {
+	t := textType
+	t.Parse = func(s string) (driver.Value, error) {
+		for _, v := range values {
+			if s == v {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid enum value %#v, expected one of %q", s, values)
+	}
+
+	return t
+}
+
+var typeModifiers = regexp.MustCompile(`\([^)]*\)`)
+
+func normalizeTypeName(name string) string {
+	name = typeModifiers.ReplaceAllString(strings.ToLower(name), "")
+	return strings.Join(strings.Fields(name), " ")
+}
+
+var textType = Type{
+	Parse: func(s string) (driver.Value, error) { return s, nil },
+	Convert: func(v driver.Value) (driver.Value, error) {
+		if b, ok := v.([]byte); ok {
+			return string(b), nil
+		}
+		return v, nil
+	},
+	Zero: "",
+}
+
+var uuidType = Type{
+	Parse: parseUUID,
+	Convert: func(v driver.Value) (driver.Value, error) {
+		switch v := v.(type) {
+		case string:
+			return parseUUID(v)
+		case []byte:
+			if len(v) == 16 {
+				return uuid.UUID(v).String(), nil
+			}
+			return parseUUID(string(v))
+		default:
+			return nil, fmt.Errorf("unsupported uuid value %T", v)
+		}
+	},
+	Zero: "",
+}
+
+func parseUUID(s string) (driver.Value, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return id.String(), nil
+}
+
+var boolType = Type{
+	Parse: func(s string) (driver.Value, error) {
+		v, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean value %#v", s)
+		}
+		return v, nil
+	},
+	Convert: func(v driver.Value) (driver.Value, error) {
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		return driver.Bool.ConvertValue(v)
+	},
+	Zero: false,
+}
+
+func intType(bitSize int) Type {
+	parse := func(s string) (driver.Value, error) {
+		v, err := strconv.ParseInt(strings.TrimSpace(s), 10, bitSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v-bit integer value %#v", bitSize, s)
+		}
+		return v, nil
+	}
+
+	return Type{
+		Parse: parse,
+		Convert: func(v driver.Value) (driver.Value, error) {
+			switch v := v.(type) {
+			case int64:
+				return v, nil
+			case string:
+				return parse(v)
+			case []byte:
+				return parse(string(v))
+			case uint64:
+				if v > math.MaxInt64 {
+					return nil, fmt.Errorf("integer value %v overflows int64", v)
+				}
+				return int64(v), nil
+			default:
+				return nil, fmt.Errorf("unsupported integer value %T", v)
+			}
+		},
+		Zero: int64(0),
+	}
+}
+
+func floatType(bitSize int) Type {
+	parse := func(s string) (driver.Value, error) {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), bitSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v-bit float value %#v", bitSize, s)
+		}
+		return v, nil
+	}
+
+	return Type{
+		Parse: parse,
+		Convert: func(v driver.Value) (driver.Value, error) {
+			switch v := v.(type) {
+			case float64:
+				return v, nil
+			case int64:
+				return float64(v), nil
+			case string:
+				return parse(v)
+			case []byte:
+				return parse(string(v))
+			default:
+				return nil, fmt.Errorf("unsupported float value %T", v)
+			}
+		},
+		Zero: float64(0),
+	}
+}
+
+// numeric values are kept as decimal strings, so they are not rounded.
+var numericType = Type{
+	Parse: parseDecimal,
+	Convert: func(v driver.Value) (driver.Value, error) {
+		switch v := v.(type) {
+		case string:
+			return parseDecimal(v)
+		case []byte:
+			return parseDecimal(string(v))
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case float64:
+			return parseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			return nil, fmt.Errorf("unsupported numeric value %T", v)
+		}
+	},
+	Zero: "0",
+}
+
+// parseDecimal returns decimal number in the shortest form, e.g. "1.50" is
+// "1.5", and "1e3" is "1000".
+func parseDecimal(s string) (driver.Value, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "nan") {
+		return "NaN", nil
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.Contains(s, "/") {
+		return nil, fmt.Errorf("invalid numeric value %#v", s)
+	}
+
+	// every decimal fraction has denominator 2^a * 5^b, and needs max(a, b)
+	// digits after point.
+	var twos, fives int
+	d := new(big.Int).Set(r.Denom())
+	for m := new(big.Int); ; twos++ {
+		if d.QuoRem(d, big.NewInt(2), m); m.Sign() != 0 {
+			d.Mul(d, big.NewInt(2)).Add(d, m)
+			break
+		}
+	}
+	for m := new(big.Int); ; fives++ {
+		if d.QuoRem(d, big.NewInt(5), m); m.Sign() != 0 {
+			d.Mul(d, big.NewInt(5)).Add(d, m)
+			break
+		}
+	}
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return nil, fmt.Errorf("invalid numeric value %#v", s)
+	}
+
+	res := r.FloatString(max(twos, fives))
+	return res, nil
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.DateOnly,
+}
+
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid timestamp %#v", s)
+}
+
+func convertTime(v driver.Value) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC(), nil
+	case string:
+		return parseTime(v)
+	case []byte:
+		return parseTime(string(v))
+	default:
+		return time.Time{}, fmt.Errorf("unsupported timestamp value %T", v)
+	}
+}
+
+var timestampType = Type{
+	Parse:   func(s string) (driver.Value, error) { return parseTime(s) },
+	Convert: func(v driver.Value) (driver.Value, error) { return convertTime(v) },
+	Zero:    time.Time{},
+}
+
+// dates are midnights of UTC.
+var dateType = Type{
+	Parse: func(s string) (driver.Value, error) {
+		t, err := parseTime(s)
+		return t.Truncate(24 * time.Hour), err
+	},
+	Convert: func(v driver.Value) (driver.Value, error) {
+		if t, ok := v.(time.Time); ok {
+			// date without time zone is the same in any location.
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+		t, err := convertTime(v)
+		return t.Truncate(24 * time.Hour), err
+	},
+	Zero: time.Time{},
+}
+
+// time of day is kept as string, e.g. "15:04:05.5".
+var timeOfDayType = Type{
+	Parse: parseTimeOfDay,
+	Convert: func(v driver.Value) (driver.Value, error) {
+		switch v := v.(type) {
+		case string:
+			return parseTimeOfDay(v)
+		case []byte:
+			return parseTimeOfDay(string(v))
+		case time.Time:
+			return v.Format("15:04:05.999999999"), nil
+		default:
+			return nil, fmt.Errorf("unsupported time value %T", v)
+		}
+	},
+	Zero: "",
+}
+
+func parseTimeOfDay(s string) (driver.Value, error) {
+	t, err := time.Parse("15:04:05.999999999", strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %#v", s)
+	}
+
+	return t.Format("15:04:05.999999999"), nil
+}
+
+// time with time zone is kept as string with numeric offset, e.g.
+// "03:04:05+03:00", since it's not a point in time.
+var timeOfDayTZType = Type{
+	Parse: parseTimeOfDayTZ,
+	Convert: func(v driver.Value) (driver.Value, error) {
+		switch v := v.(type) {
+		case string:
+			return parseTimeOfDayTZ(v)
+		case []byte:
+			return parseTimeOfDayTZ(string(v))
+		case time.Time:
+			return v.Format(timeOfDayTZLayout), nil
+		default:
+			return nil, fmt.Errorf("unsupported time value %T", v)
+		}
+	},
+	Zero: "",
+}
+
+const timeOfDayTZLayout = "15:04:05.999999999Z07:00"
+
+func parseTimeOfDayTZ(s string) (driver.Value, error) {
+	// postgres omits minutes of offset, when they are zero, e.g. "+03".
+	for _, layout := range []string{timeOfDayTZLayout, "15:04:05.999999999Z07"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return t.Format(timeOfDayTZLayout), nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid time with time zone %#v", s)
+}
+
+// interval is kept as string in postgres format, e.g. "1 mon 2 days 03:04:05".
+var intervalType = Type{
+	Parse: parseInterval,
+	Convert: func(v driver.Value) (driver.Value, error) {
+		switch v := v.(type) {
+		case string:
+			return parseInterval(v)
+		case []byte:
+			return parseInterval(string(v))
+		case int64: // microseconds
+			return formatInterval(0, 0, time.Duration(v)*time.Microsecond), nil
+		default:
+			return nil, fmt.Errorf("unsupported interval value %T", v)
+		}
+	},
+	Zero: "",
+}
+
+var intervalUnits = map[string]struct {
+	months, days int
+	dur          time.Duration
+}{
+	"year": {months: 12}, "years": {months: 12},
+	"mon": {months: 1}, "mons": {months: 1}, "month": {months: 1}, "months": {months: 1},
+	"week": {days: 7}, "weeks": {days: 7},
+	"day": {days: 1}, "days": {days: 1},
+	"hour": {dur: time.Hour}, "hours": {dur: time.Hour},
+	"min": {dur: time.Minute}, "mins": {dur: time.Minute}, "minute": {dur: time.Minute}, "minutes": {dur: time.Minute},
+	"sec": {dur: time.Second}, "secs": {dur: time.Second}, "second": {dur: time.Second}, "seconds": {dur: time.Second},
+}
+
+// parseInterval accepts intervals in postgres format, e.g. "1 year 2 mons
+// -3 days +04:05:06.5", and go durations, e.g. "1h30m".
+func parseInterval(s string) (driver.Value, error) {
+	if d, err := time.ParseDuration(strings.TrimSpace(s)); err == nil {
+		return formatInterval(0, 0, d), nil
+	}
+
+	var months, days int
+	var dur time.Duration
+	fields := strings.Fields(s)
+	for i := 0; i < len(fields); i++ {
+		if strings.Contains(fields[i], ":") {
+			d, err := parseClock(fields[i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid interval %#v", s)
+			}
+			dur += d
+			continue
+		}
+
+		n, err := strconv.Atoi(fields[i])
+		if err != nil || i+1 == len(fields) {
+			return nil, fmt.Errorf("invalid interval %#v", s)
+		}
+		unit, ok := intervalUnits[fields[i+1]]
+		if !ok {
+			return nil, fmt.Errorf("invalid interval %#v: unknown unit %#v", s, fields[i+1])
+		}
+		months, days = months+n*unit.months, days+n*unit.days
+		dur += time.Duration(n) * unit.dur
+		i++
+	}
+
+	return formatInterval(months, days, dur), nil
+}
+
+// parseClock parses "[+-]hh:mm[:ss[.fraction]]".
+func parseClock(s string) (time.Duration, error) {
+	sign := time.Duration(1)
+	if rest, ok := strings.CutPrefix(s, "-"); ok {
+		s, sign = rest, -1
+	}
+	s = strings.TrimPrefix(s, "+")
+
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, errors.New("invalid clock")
+	}
+
+	var res time.Duration
+	for i, unit := range []time.Duration{time.Hour, time.Minute} {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return 0, err
+		}
+		res += time.Duration(n) * unit
+	}
+	if len(parts) == 3 {
+		sec, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return 0, err
+		}
+		res += time.Duration(sec * float64(time.Second))
+	}
+
+	return sign * res, nil
+}
+
+func formatInterval(months, days int, dur time.Duration) string {
+	var parts []string
+	plural := func(n int, unit string) string {
+		if n == 1 || n == -1 {
+			return fmt.Sprintf("%v %v", n, unit)
+		}
+		return fmt.Sprintf("%v %vs", n, unit)
+	}
+	if years := months / 12; years != 0 {
+		parts = append(parts, plural(years, "year"))
+	}
+	if months%12 != 0 {
+		parts = append(parts, plural(months%12, "mon"))
+	}
+	if days != 0 {
+		parts = append(parts, plural(days, "day"))
+	}
+
+	if dur != 0 || len(parts) == 0 {
+		sign := ""
+		if dur < 0 {
+			sign, dur = "-", -dur
+		}
+		clock := fmt.Sprintf("%v%02d:%02d:%02d", sign, int(dur/time.Hour), int(dur%time.Hour/time.Minute), int(dur%time.Minute/time.Second))
+		if frac := dur % time.Second; frac != 0 {
+			clock += strings.TrimRight(fmt.Sprintf(".%09d", frac), "0")
+		}
+		parts = append(parts, clock)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// bytea fixtures are written as hex with "\x" prefix, like postgres prints
+// them, or as raw text otherwise.
+var bytesType = Type{
+	Parse: parseBytes,
+	Convert: func(v driver.Value) (driver.Value, error) {
+		switch v := v.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			return parseBytes(v)
+		default:
+			return nil, fmt.Errorf("unsupported bytea value %T", v)
+		}
+	},
+	Zero: []byte{},
+}
+
+func parseBytes(s string) (driver.Value, error) {
+	if h, ok := strings.CutPrefix(s, `\x`); ok {
+		return hex.DecodeString(h)
+	}
+
+	return []byte(s), nil
+}
+
+// json is kept as string, and compared by its content, so formatting and
+// order of object keys don't matter.
+var jsonType = Type{
+	Parse: func(s string) (driver.Value, error) {
+		if !json.Valid([]byte(s)) {
+			return nil, fmt.Errorf("invalid json %#v", s)
+		}
+		return s, nil
+	},
+	Convert: func(v driver.Value) (driver.Value, error) {
+		switch v := v.(type) {
+		case string:
+			return v, nil
+		case []byte:
+			return string(v), nil
+		default:
+			return nil, fmt.Errorf("unsupported json value %T", v)
+		}
+	},
+	Equal: func(a, b driver.Value) bool {
+		var aJSON, bJSON any
+		return decodeJSONValue(a, &aJSON) == nil && decodeJSONValue(b, &bJSON) == nil && reflect.DeepEqual(aJSON, bJSON)
+	},
+	Zero: "null",
+}
+
+func decodeJSONValue(v driver.Value, dst any) error {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("unsupported json value %T", v)
+	}
+
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	return d.Decode(dst)
+}
+
+// inet and cidr values are kept as strings, where host addresses are written
+// without mask, e.g. "10.0.0.1" and "10.0.0.0/8".
+var inetType = Type{
+	Parse: parseInet,
+	Convert: func(v driver.Value) (driver.Value, error) {
+		switch v := v.(type) {
+		case string:
+			return parseInet(v)
+		case []byte:
+			return parseInet(string(v))
+		default:
+			return nil, fmt.Errorf("unsupported inet value %T", v)
+		}
+	},
+	Zero: "",
+}
+
+func parseInet(s string) (driver.Value, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, err
+		}
+		return addr.String(), nil
+	}
+
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return nil, err
+	}
+	if prefix.IsSingleIP() {
+		return prefix.Addr().String(), nil
+	}
+
+	return prefix.String(), nil
+}
+
+// arrayType returns type of one-dimensional arrays, which are written in
+// postgres format, e.g. `{1,2,NULL}` or `{"a b","c"}`. Arrays are kept as
+// strings, and compared element by element.
+func arrayType(elem Type) Type {
+	parse := func(s string) (driver.Value, error) {
+		items, err := parseArray(s)
+		if err != nil {
+			return nil, err
+		}
+		for i, item := range items {
+			if item == nil {
+				continue
+			}
+			if _, err := elem.Parse(*item); err != nil {
+				return nil, fmt.Errorf("element %v: %w", i, err)
+			}
+		}
+		return s, nil
+	}
+
+	return Type{
+		Parse: parse,
+		Convert: func(v driver.Value) (driver.Value, error) {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			if s, ok := v.(string); ok {
+				return parse(s)
+			}
+			return nil, fmt.Errorf("unsupported array value %T", v)
+		},
+		Equal: func(a, b driver.Value) bool {
+			aItems, aErr := parseArrayValue(a)
+			bItems, bErr := parseArrayValue(b)
+			if aErr != nil || bErr != nil || len(aItems) != len(bItems) {
+				return false
+			}
+			for i := range aItems {
+				if (aItems[i] == nil) != (bItems[i] == nil) {
+					return false
+				} else if aItems[i] == nil {
+					continue
+				}
+
+				aItem, aErr := elem.Parse(*aItems[i])
+				bItem, bErr := elem.Parse(*bItems[i])
+				if aErr != nil || bErr != nil || !elem.equal(aItem, bItem) {
+					return false
+				}
+			}
+			return true
+		},
+		Zero: "{}",
+	}
+}
+
+func parseArrayValue(v driver.Value) ([]*string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("unsupported array value %T", v)
+	}
+
+	return parseArray(s)
+}
+
+// parseArray splits one-dimensional postgres array into elements, NULL
+// elements are nil.
+func parseArray(s string) ([]*string, error) {
+	body, ok := strings.CutPrefix(strings.TrimSpace(s), "{")
+	if body, ok = strings.CutSuffix(body, "}"); !ok {
+		return nil, fmt.Errorf("invalid array %#v", s)
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil, nil
+	}
+
+	var res []*string
+	var item bytes.Buffer
+	var quoted, wasQuoted bool
+	flush := func() {
+		text := item.String()
+		if !wasQuoted {
+			text = strings.TrimSpace(text)
+		}
+		if !wasQuoted && strings.EqualFold(text, "null") {
+			res = append(res, nil)
+		} else {
+			res = append(res, &text)
+		}
+		item.Reset()
+		wasQuoted = false
+	}
+
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; {
+		case c == '\\' && i+1 < len(body):
+			i++
+			item.WriteByte(body[i])
+		case c == '"':
+			quoted, wasQuoted = !quoted, true
+		case c == '{' && !quoted:
+			return nil, fmt.Errorf("invalid array %#v: multidimensional arrays are not supported", s)
+		case c == ',' && !quoted:
+			flush()
+		default:
+			item.WriteByte(c)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("invalid array %#v: unterminated quote", s)
+	}
+	flush()
+
+	return res, nil
+}
@@ -0,0 +1,115 @@
+package tabsync
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypes(t *testing.T) {
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	stamp := time.Date(2024, 1, 2, 3, 4, 5, 500000000, time.UTC)
+
+	for _, tt := range []struct {
+		typ     string
+		fixture string
+		got     driver.Value // value, returned by driver
+		want    driver.Value // canonical value
+	}{
+		{typ: "text", fixture: "abc", got: []byte("abc"), want: "abc"},
+		{typ: "VARCHAR(16)", fixture: "abc", got: "abc", want: "abc"},
+		{typ: "uuid", fixture: "0B7B3C3E-8D5C-4A39-9D6C-1F7A2B3C4D5E", got: "0b7b3c3e-8d5c-4a39-9d6c-1f7a2b3c4d5e", want: "0b7b3c3e-8d5c-4a39-9d6c-1f7a2b3c4d5e"},
+		{typ: "boolean", fixture: "t", got: int64(1), want: true},
+		{typ: "bool", fixture: "false", got: []byte("0"), want: false},
+		{typ: "smallint", fixture: "-5", got: []byte("-5"), want: int64(-5)},
+		{typ: "bigint", fixture: "9007199254740993", got: int64(9007199254740993), want: int64(9007199254740993)},
+		{typ: "double precision", fixture: "1.5", got: "1.5", want: 1.5},
+		{typ: "real", fixture: "0.1", got: float64(float32(0.1)), want: float64(float32(0.1))},
+		{typ: "numeric(10,2)", fixture: "1.50", got: "1.5000", want: "1.5"},
+		{typ: "decimal", fixture: "1e3", got: int64(1000), want: "1000"},
+		{typ: "date", fixture: "2024-01-02", got: time.Date(2024, 1, 2, 0, 0, 0, 0, time.FixedZone("", 3600)), want: date},
+		{typ: "timestamp", fixture: "2024-01-02 03:04:05.5", got: []byte("2024-01-02 03:04:05.5"), want: stamp},
+		{typ: "timestamptz", fixture: "2024-01-02T05:04:05.5+02:00", got: stamp.In(time.FixedZone("", -3600)), want: stamp},
+		{typ: "time", fixture: "03:04:05", got: []byte("03:04:05.000"), want: "03:04:05"},
+		{typ: "timetz", fixture: "03:04:05+03:00", got: "03:04:05+03", want: "03:04:05+03:00"},
+		{typ: "time with time zone", fixture: "03:04:05.5Z", got: time.Date(0, 1, 1, 3, 4, 5, 500000000, time.UTC), want: "03:04:05.5Z"},
+		{typ: "interval", fixture: "1h30m", got: "01:30:00", want: "01:30:00"},
+		{typ: "interval", fixture: "1 day 2 hours 30 minutes 5 secs", got: "1 day 02:30:05", want: "1 day 02:30:05"},
+		{typ: "interval", fixture: "14 mons 1 day 00:00:01.5", got: "1 year 2 mons 1 day 00:00:01.5", want: "1 year 2 mons 1 day 00:00:01.5"},
+		{typ: "bytea", fixture: `\x0102`, got: []byte{1, 2}, want: []byte{1, 2}},
+		{typ: "jsonb", fixture: `{"b": 1, "a": [true]}`, got: []byte(`{"a": [true], "b": 1}`)},
+		{typ: "inet", fixture: "10.0.0.1/32", got: "10.0.0.1", want: "10.0.0.1"},
+		{typ: "cidr", fixture: "10.0.0.0/8", got: []byte("10.0.0.0/8"), want: "10.0.0.0/8"},
+		{typ: "int[]", fixture: `{1, 2,NULL}`, got: "{1,2,NULL}"},
+		{typ: "_text", fixture: `{"a,b",c}`, got: `{"a,b","c"}`},
+	} {
+		t.Run(tt.typ+"/"+tt.fixture, func(t *testing.T) {
+			typ, ok := LookupType(tt.typ)
+			require.True(t, ok)
+
+			parsed, err := typ.Parse(tt.fixture)
+			require.NoError(t, err)
+			got, err := typ.convert(tt.got)
+			require.NoError(t, err)
+
+			if tt.want != nil {
+				require.Equal(t, tt.want, parsed)
+				require.Equal(t, tt.want, got)
+			}
+			require.True(t, typ.equal(parsed, got))
+			require.NoError(t, constValidator{value: parsed, typ: typ}.Validate(tt.got))
+		})
+	}
+}
+
+func TestTypesInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		typ     string
+		fixture string
+		wantErr string
+	}{
+		{typ: "smallint", fixture: "40000", wantErr: `invalid 16-bit integer value "40000"`},
+		{typ: "numeric", fixture: "1/3", wantErr: `invalid numeric value "1/3"`},
+		{typ: "interval", fixture: "1 fortnight", wantErr: `invalid interval "1 fortnight": unknown unit "fortnight"`},
+		{typ: "json", fixture: "{", wantErr: `invalid json "{"`},
+		{typ: "int[]", fixture: "{1,x}", wantErr: `element 1: invalid 32-bit integer value "x"`},
+		{typ: "int[]", fixture: "{{1},{2}}", wantErr: `invalid array "{{1},{2}}": multidimensional arrays are not supported`},
+	} {
+		t.Run(tt.typ+"/"+tt.fixture, func(t *testing.T) {
+			_, err := convertTo(tt.typ, tt.fixture)
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRegisterType(t *testing.T) {
+	RegisterType("Mood", Enum("sad", "happy"))
+	RegisterType("email", MustLookupType("text"))
+
+	_, err := convertTo("mood", "angry")
+	require.EqualError(t, err, `invalid enum value "angry", expected one of ["sad" "happy"]`)
+
+	v, err := newValidator(nil)("", "?mood[]", "{happy,sad}")
+	require.NoError(t, err)
+	require.NoError(t, v.Validate([]byte(`{"happy","sad"}`)))
+	require.EqualError(t, v.Validate("{happy}"), `mismatched values: got "{happy}", want "{happy,sad}"`)
+
+	v, err = newValidator(nil)("", "email", `=value endsWith "@example.com"`)
+	require.NoError(t, err)
+	require.NoError(t, v.Validate([]byte("john@example.com")))
+}
+
+func TestRegisterTypeParseOnly(t *testing.T) {
+	RegisterType("upper", Type{Parse: func(s string) (driver.Value, error) { return strings.ToUpper(s), nil }})
+
+	v, err := newValidator(nil)("", "upper", "john")
+	require.NoError(t, err)
+	require.NoError(t, v.Validate("JOHN"))
+
+	v, err = newValidator(nil)("", "upper", `=value startsWith "J"`)
+	require.NoError(t, err)
+	require.NoError(t, v.Validate("JOHN"))
+}
@@ -7,8 +7,6 @@ import (
 	"slices"
 	"strings"
 	"time"
-
-	"github.com/google/uuid"
 )
 
 func cmpValue(nullFirst bool) func(_, _ driver.Value) int {
@@ -128,6 +126,16 @@ func cmpBool(a bool, b driver.Value) int {
 	}
 }
 
+// rowKeys returns values of primary keys of the row.
+func rowKeys(pkeys []string, row map[string]driver.Value) map[string]driver.Value {
+	res := make(map[string]driver.Value, len(pkeys))
+	for _, key := range pkeys {
+		res[key] = row[key]
+	}
+
+	return res
+}
+
 func rowValidatorPkeys(v map[string]Validator, pkeys []string) (map[string]driver.Value, error) {
 	res := map[string]driver.Value{}
 	for _, key := range pkeys {
@@ -143,6 +151,8 @@ func rowValidatorPkeys(v map[string]Validator, pkeys []string) (map[string]drive
 	return res, nil
 }
 
+// convertTo parses value of the fixture cell. Types with "?" prefix are
+// nullable, and accept "null" literal.
 func convertTo(typ, value string) (driver.Value, error) {
 	if strings.HasPrefix(typ, "?") {
 		if strings.EqualFold(value, "null") {
@@ -151,34 +161,24 @@ func convertTo(typ, value string) (driver.Value, error) {
 		typ = strings.TrimPrefix(typ, "?")
 	}
 
-	switch typ {
-	case "text":
-		return driver.String.ConvertValue(value)
-	case "uuid":
-		return uuid.Parse(value)
-	case "integer", "int":
-		return driver.Int32.ConvertValue(value)
-	default:
-		panic(fmt.Sprintf("type %#v not found", typ))
+	t, ok := LookupType(typ)
+	if !ok {
+		return nil, fmt.Errorf("type %#v not found", typ)
 	}
+
+	return t.Parse(value)
 }
 
-func getType(typ string) (ref driver.Value, nullable bool) {
+func getType(typ string) (t Type, nullable bool, err error) {
 	if strings.HasPrefix(typ, "?") {
 		nullable = true
 		typ = strings.TrimPrefix(typ, "?")
 	}
 
-	switch typ {
-	case "text":
-		ref = string("")
-	case "uuid":
-		ref = string("")
-	case "integer", "int":
-		ref = int64(0)
-	default:
-		panic(fmt.Sprintf("type %#v not found", typ))
+	t, ok := LookupType(typ)
+	if !ok {
+		return Type{}, false, fmt.Errorf("type %#v not found", typ)
 	}
 
-	return ref, nullable
+	return t, nullable, nil
 }
@@ -2,14 +2,15 @@ package tabsync
 
 import (
 	"database/sql/driver"
-	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/vm"
+	"github.com/quenbyako/ext/maps"
 	"github.com/quenbyako/ext/slices"
-	"github.com/quenbyako/sqltest"
+	"github.com/quenbyako/sqltest/dbenv"
 )
 
 type Validator interface {
@@ -17,130 +18,391 @@ type Validator interface {
 	AsValue() (driver.Value, bool)
 }
 
-func newValue(column, typ, s string) (driver.Value, error) {
-	if s[0] != '=' {
-		return convertTo(typ, s)
-	}
+// newValueParser returns parser of fixture cells. Sequences of seq function
+// are counted per column, so every file starts them from 1.
+func newValueParser() func(column, typ, s string) (driver.Value, error) {
+	seqs := make(map[string]*int64)
 
-	e := newValueExprEnv()
-	prog, err := expr.Compile(s[1:], expr.Env(e))
-	if err != nil {
-		return "", err
-	}
+	return func(column, typ, s string) (driver.Value, error) {
+		if !strings.HasPrefix(s, "=") {
+			return convertTo(typ, s)
+		}
 
-	value, err := expr.Run(prog, e)
-	if err != nil {
-		return "", err
-	}
+		seq, ok := seqs[column]
+		if !ok {
+			seq = new(int64)
+			seqs[column] = seq
+		}
+
+		e := newValueExprEnv(typ, seq)
+		prog, err := expr.Compile(s[1:], expr.Env(e))
+		if err != nil {
+			return "", err
+		}
+
+		value, err := expr.Run(prog, e)
+		if err != nil {
+			return "", err
+		}
 
-	return convertTo(typ, fmt.Sprint(value))
+		return exprValue(typ, value)
+	}
 }
 
 func newValidator(pkeys []string) func(column, typ, s string) (Validator, error) {
 	return func(column, typ, s string) (Validator, error) {
-		if s[0] != '=' {
+		t, nullable, err := getType(typ)
+		if err != nil {
+			return nil, err
+		}
+
+		if !strings.HasPrefix(s, "=") {
 			value, err := convertTo(typ, s)
 			if err != nil {
 				return nil, err
 			}
-			return constValidator{value: value}, nil
+			return constValidator{value: value, typ: t}, nil
 		}
 
 		if slices.Contains(pkeys, column) {
 			return nil, fmt.Errorf("found %#v value for %#v column: primary keys can't be formulas", s, column)
 		}
 
-		fuzzed, nullable := getType(typ)
-
-		// предварительная проверка что оно хотя бы заработает без ошибок
-		e := newValidatorExprEnv(typ, fuzzed)
-		prog, err := expr.Compile(s[1:], expr.Env(e), expr.AsBool())
-		if err != nil {
-			return nil, err
+		// expression is only compiled with sample value of the type, so
+		// names and types are checked, but nothing is evaluated: functions
+		// may fail on sample value, e.g. jsonPath returns nil for "null".
+		e := newValidatorExprEnv(typ, t.Zero)
+		opts := []expr.Option{expr.Env(e), expr.AsBool()}
+		if t.Zero == nil {
+			// type without sample value doesn't declare type of values, so
+			// value is not typed.
+			delete(e, "value")
+			opts = append(opts, expr.AllowUndefinedVariables())
 		}
 
-		val, err := expr.Run(prog, e)
+		prog, err := expr.Compile(s[1:], opts...)
 		if err != nil {
-			return nil, fmt.Errorf("evaluating: %w", err)
-		}
-
-		if _, ok := val.(bool); !ok {
-			return nil, fmt.Errorf("expression returns non boolean value: %v", s)
+			return nil, err
 		}
 
-		return exprValidator{typName: typ, typ: fuzzed, nullable: nullable, prog: prog}, nil
+		return exprValidator{typName: typ, typ: t, nullable: nullable, prog: prog}, nil
 	}
 }
 
-func validateTable(got sqltest.TableData, want []map[string]Validator) error {
+// validateTable checks expected rows, which are matched with rows of the
+// table by primary key, or by all validators, if table has no primary key. In
+// strict mode rows of the table, which are not expected, are reported too.
+func validateTable(got dbenv.TableData, want []map[string]Validator, strict bool) error {
+	return matchTable(got, want, strict).err()
+}
+
+func matchTable(got dbenv.TableData, want []map[string]Validator, strict bool) TableMismatch {
 	if len(got.Schema.PrimaryKeys) == 0 {
-		return errors.New("required at least one primary key, otherwise can't match rows")
+		return matchMultiset(got, want, strict)
 	}
 
-	got.Rows = slices.SortFunc(got.Rows, func(a, b sqltest.TableRow) int {
+	res := TableMismatch{Keys: got.Schema.PrimaryKeys}
+
+	// keys of actual rows are converted with types of expected keys, so they
+	// are compared with parsed values, e.g. numeric "1.50" with "1.5".
+	keyTypes := pkeyTypes(want, got.Schema.PrimaryKeys)
+	gotKeys := make([]map[string]driver.Value, len(got.Rows))
+	for i, row := range got.Rows {
+		gotKeys[i] = convertKeys(keyTypes, rowKeys(got.Schema.PrimaryKeys, row))
+	}
+	cmpKeys := func(a, b map[string]driver.Value) int {
 		for _, key := range got.Schema.PrimaryKeys {
+			if keyTypes[key].equal(a[key], b[key]) {
+				continue
+			}
 			if v := cmpValue(false)(a[key], b[key]); v != 0 {
 				return v
 			}
 		}
 		return 0
+	}
+
+	order := slices.SortFunc(slices.Generate(len(got.Rows), func(i int) int { return i }), func(a, b int) int {
+		return cmpKeys(gotKeys[a], gotKeys[b])
 	})
+	got.Rows = slices.Remap(order, func(i int) dbenv.TableRow { return got.Rows[i] })
+	gotKeys = slices.Remap(order, func(i int) map[string]driver.Value { return gotKeys[i] })
 	want = slices.SortFunc(want, func(a, b map[string]Validator) int {
-		// if value is primary key and can't be converted to constant value, it should be sorted as last
-		for _, key := range got.Schema.PrimaryKeys {
-			aVal, ok := a[key]
-			if !ok {
-				if _, ok := b[key]; !ok {
-					return 0
-				}
-				return 1
+		return cmpConst(got.Schema.PrimaryKeys, a, b)
+	})
+
+	matched := make([]bool, len(got.Rows))
+	for _, want := range want {
+		rowPkeys, err := rowValidatorPkeys(want, got.Schema.PrimaryKeys)
+		if err != nil {
+			res.Rows = append(res.Rows, RowMismatch{Kind: RowInvalid, Want: want, Err: err})
+			continue
+		}
+
+		i, ok := slices.BinarySearchFunc(gotKeys, rowPkeys, cmpKeys)
+		if isAbsent(want) {
+			if ok {
+				res.Rows = append(res.Rows, RowMismatch{Kind: RowForbidden, Row: rowPkeys, Want: want, Got: got.Rows[i]})
 			}
-			bVal, ok := b[key]
-			if !ok {
-				return -1
+			continue
+		} else if !ok {
+			res.Rows = append(res.Rows, RowMismatch{Kind: RowMissing, Row: rowPkeys, Want: want})
+			continue
+		}
+
+		matched[i] = true
+		if cells := validateRow(got.Rows[i], inferNullable(got.Schema, want)); len(cells) > 0 {
+			res.Rows = append(res.Rows, RowMismatch{Kind: RowDiffers, Row: rowPkeys, Want: want, Got: got.Rows[i], Cells: cells})
+		}
+	}
+
+	if strict {
+		for i, row := range got.Rows {
+			if !matched[i] {
+				res.Rows = append(res.Rows, RowMismatch{Kind: RowUnexpected, Row: rowKeys(got.Schema.PrimaryKeys, row), Got: row})
 			}
+		}
+	}
 
-			if v := cmpValue(false)(aVal, bVal); v != 0 {
-				return v
+	return res
+}
+
+// matchMultiset matches expected rows with rows of the table, when there is
+// no key to find them. Expected row can be paired with any row, which passes
+// all its validators, and pairs are selected with maximum bipartite matching,
+// so neither order nor duplicates of rows matter. Expected row without pair is
+// reported with differences of the closest unpaired row.
+func matchMultiset(got dbenv.TableData, want []map[string]Validator, strict bool) TableMismatch {
+	var res TableMismatch
+
+	fits := make([][]int, len(want)) // indexes of rows, which pass validators
+	absent := make([]bool, len(want))
+	for i, w := range want {
+		w, absent[i] = withoutAbsent(w)
+		w = inferNullable(got.Schema, w)
+		for j, row := range got.Rows {
+			if len(validateRow(row, w)) == 0 {
+				fits[i] = append(fits[i], j)
 			}
 		}
 
-		return 0
-	})
+		if absent[i] {
+			if len(fits[i]) > 0 {
+				res.Rows = append(res.Rows, RowMismatch{Kind: RowForbidden, Row: i, Want: want[i], Got: got.Rows[fits[i][0]]})
+			}
+			fits[i] = nil
+		}
+	}
 
-	var errs []error
-	for _, want := range want {
-		rowPkeys, err := rowValidatorPkeys(want, got.Schema.PrimaryKeys)
-		if err != nil {
-			panic(err)
+	owners := matchRows(fits, len(got.Rows))
+	matched := make([]bool, len(want))
+	for _, i := range owners {
+		if i >= 0 {
+			matched[i] = true
 		}
+	}
 
-		i, ok := slices.BinarySearchFunc(got.Rows, 0, func(row sqltest.TableRow, i int) int {
-			for _, key := range got.Schema.PrimaryKeys {
-				if v := cmpValue(false)(row[key], rowPkeys[key]); v != 0 {
-					return v
-				}
+	for i, w := range want {
+		if matched[i] || absent[i] {
+			continue
+		}
+
+		mismatch := RowMismatch{Kind: RowMissing, Row: i, Want: w}
+		w = inferNullable(got.Schema, w)
+		for j, row := range got.Rows {
+			if owners[j] >= 0 {
+				continue
+			}
+			if cells := validateRow(row, w); mismatch.Got == nil || len(cells) < len(mismatch.Cells) {
+				mismatch.Got, mismatch.Cells = row, cells
+			}
+		}
+		res.Rows = append(res.Rows, mismatch)
+	}
+
+	if strict {
+		for j, row := range got.Rows {
+			if owners[j] < 0 {
+				res.Rows = append(res.Rows, RowMismatch{Kind: RowUnexpected, Row: map[string]driver.Value(row), Got: row})
+			}
+		}
+	}
+
+	return res
+}
+
+// matchRows finds maximum matching of expected and actual rows with augmenting
+// paths. fits contains indexes of actual rows, which fit every expected row.
+// It returns index of paired expected row for every actual row, or -1.
+func matchRows(fits [][]int, rows int) []int {
+	owners := make([]int, rows)
+	for j := range owners {
+		owners[j] = -1
+	}
+
+	var seen []bool
+	var augment func(i int) bool
+	augment = func(i int) bool {
+		for _, j := range fits[i] {
+			if seen[j] {
+				continue
+			}
+			seen[j] = true
+
+			if owners[j] < 0 || augment(owners[j]) {
+				owners[j] = i
+				return true
 			}
-			return 0
-		})
-		if !ok {
-			return fmt.Errorf("row %#v: not found in database", rowPkeys)
 		}
+		return false
+	}
 
-		for k, wantItem := range want {
-			if gotItem, ok := got.Rows[i][k]; !ok {
-				errs = append(errs, fmt.Errorf("row %#v: key %q: not found", rowPkeys, k))
-			} else if err := wantItem.Validate(gotItem); err != nil {
-				errs = append(errs, fmt.Errorf("row %#v: key %q: %w", rowPkeys, k, err))
+	for i := range fits {
+		if len(fits[i]) > 0 {
+			seen = make([]bool, rows)
+			augment(i)
+		}
+	}
+
+	return owners
+}
+
+// pkeyTypes returns types of constant primary keys of expected rows.
+func pkeyTypes(want []map[string]Validator, pkeys []string) map[string]Type {
+	res := make(map[string]Type, len(pkeys))
+	for _, row := range want {
+		row, _ = withoutAbsent(row)
+		for _, key := range pkeys {
+			if _, ok := res[key]; ok {
+				continue
+			} else if c, ok := row[key].(constValidator); ok {
+				res[key] = c.typ
 			}
 		}
 	}
 
-	return errors.Join(errs...)
+	return res
+}
+
+// convertKeys converts key values with their types. Values, which can't be
+// converted, are kept as is, so they just don't match expected keys.
+func convertKeys(types map[string]Type, keys map[string]driver.Value) map[string]driver.Value {
+	for key, value := range keys {
+		if v, err := types[key].convert(value); err == nil {
+			keys[key] = v
+		}
+	}
+
+	return keys
+}
+
+// withoutAbsent unwraps validators of expected row, which is marked with
+// NotExists.
+func withoutAbsent(want map[string]Validator) (map[string]Validator, bool) {
+	if !isAbsent(want) {
+		return want, false
+	}
+
+	res := make(map[string]Validator, len(want))
+	for k, v := range want {
+		if a, ok := v.(absentValidator); ok {
+			v = a.Validator
+		}
+		res[k] = v
+	}
+
+	return res, true
+}
+
+// matchChanged checks, that changed rows are exactly the expected ones:
+// every expected row is changed, and there is no other changed rows.
+func matchChanged(schema dbenv.TableSchema, got []dbenv.TableRow, want []map[string]Validator) TableMismatch {
+	if len(got) == 0 && len(want) == 0 {
+		return TableMismatch{}
+	}
+
+	return matchTable(dbenv.TableData{Schema: schema, Rows: got}, want, true)
+}
+
+// inferNullable allows null values in expression validators of nullable
+// columns, so column type doesn't need "?" prefix, when schema is known.
+func inferNullable(schema dbenv.TableSchema, want map[string]Validator) map[string]Validator {
+	res := make(map[string]Validator, len(want))
+	for column, v := range want {
+		if e, ok := v.(exprValidator); ok && !e.nullable {
+			if c, ok := schema.Column(column); ok && c.Nullable {
+				e.nullable = true
+				v = e
+			}
+		}
+		res[column] = v
+	}
+
+	return res
+}
+
+// validateOrdered checks rows one by one, so order of rows matters.
+func validateOrdered(got []dbenv.TableRow, want []map[string]Validator) error {
+	var res TableMismatch
+	if len(got) != len(want) {
+		res.Errors = append(res.Errors, fmt.Errorf("mismatched rows count: got %v, want %v", len(got), len(want)))
+	}
+
+	for i := 0; i < min(len(got), len(want)); i++ {
+		if cells := validateRow(got[i], want[i]); len(cells) > 0 {
+			res.Rows = append(res.Rows, RowMismatch{Kind: RowDiffers, Row: i, Want: want[i], Got: got[i], Cells: cells})
+		}
+	}
+
+	return res.err()
+}
+
+// validateRow checks every column of the row and returns cells, which don't
+// pass validators.
+func validateRow(got dbenv.TableRow, want map[string]Validator) (cells []CellMismatch) {
+	for _, k := range slices.Sort(maps.Keys(want)) {
+		if gotItem, ok := got[k]; !ok {
+			cells = append(cells, CellMismatch{Column: k, Want: want[k], Err: ErrNoColumn})
+		} else if err := want[k].Validate(gotItem); err != nil {
+			cells = append(cells, CellMismatch{Column: k, Got: gotItem, Want: want[k], Err: err})
+		}
+	}
+
+	return cells
+}
+
+// absentValidator wraps every validator of expected row, which must not exist
+// in the table, so the row is marked without extra columns.
+type absentValidator struct{ Validator }
+
+// isAbsent reports whether expected row is marked with NotExists.
+func isAbsent(row map[string]Validator) bool {
+	for _, v := range row {
+		if _, ok := v.(absentValidator); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NotExists marks expected row, so validation fails, if the row with the same
+// primary key exists in the table. For tables without primary key, row must
+// not pass all validators of the marked row.
+func NotExists(row map[string]Validator) map[string]Validator {
+	res := make(map[string]Validator, len(row))
+	for k, v := range row {
+		res[k] = absentValidator{v}
+	}
+
+	return res
 }
 
 type constValidator struct {
 	value driver.Value
+	// typ converts actual values before comparison, zero type compares
+	// values as is.
+	typ Type
 }
 
 // for sorting
@@ -180,12 +442,17 @@ func cmpConst(pkeys []string, a, b map[string]Validator) int {
 	return 0
 }
 
-func (c constValidator) Validate(s driver.Value) error {
+func (c constValidator) Validate(got driver.Value) error {
+	s, err := c.typ.convert(got)
+	if err != nil {
+		return fmt.Errorf("on %#v: %w", got, err)
+	}
+
 	if s == nil && c.value != s {
 		return fmt.Errorf("mismatched values: got %#v, want %#v", s, c.value)
 	} else if reflect.TypeOf(c.value) != reflect.TypeOf(s) {
 		return fmt.Errorf("mismatched types: got %T, want %T", s, c.value)
-	} else if !reflect.DeepEqual(c.value, s) {
+	} else if !c.typ.equal(c.value, s) {
 		return fmt.Errorf("mismatched values: got %#v, want %#v", s, c.value)
 	}
 
@@ -196,27 +463,32 @@ func (c constValidator) AsValue() (driver.Value, bool) { return c.value, true }
 
 type exprValidator struct {
 	typName  string
-	typ      driver.Value
+	typ      Type
 	nullable bool
 	prog     *vm.Program
 }
 
-func (c exprValidator) Validate(s driver.Value) error {
+func (c exprValidator) Validate(got driver.Value) error {
+	s, err := c.typ.convert(got)
+	if err != nil {
+		return fmt.Errorf("on %#v: %w", got, err)
+	}
+
 	if s == nil {
 		if !c.nullable {
 			return fmt.Errorf("not expected null value, got %#v", s)
 		}
-	} else if reflect.TypeOf(c.typ) != reflect.TypeOf(s) {
-		return fmt.Errorf("mismatched types: got %T, want %T", s, c.typ)
+	} else if c.typ.Zero != nil && reflect.TypeOf(c.typ.Zero) != reflect.TypeOf(s) {
+		return fmt.Errorf("mismatched types: got %T, want %T", s, c.typ.Zero)
 	}
 
 	val, err := expr.Run(c.prog, newValidatorExprEnv(c.typName, s))
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("on %#v, evaluating: %w", s, err)
 	}
 
 	if valid, ok := val.(bool); !ok {
-		panic(err)
+		return fmt.Errorf("expression returns non boolean value: %v", c.prog.Source().Content())
 	} else if !valid {
 		return fmt.Errorf("on %[1]q (type %[1]T), fails this expression: %[2]v", s, c.prog.Source().Content())
 	}
@@ -226,13 +498,19 @@ func (c exprValidator) Validate(s driver.Value) error {
 
 func (c exprValidator) AsValue() (driver.Value, bool) { return nil, false }
 
-func newValueExprEnv() map[string]any {
-	return map[string]any{}
+func newValueExprEnv(typ string, seq *int64) map[string]any {
+	e := exprFuncs(typ)
+	for name, f := range generatorFuncs(seq) {
+		e[name] = f
+	}
+
+	return e
 }
 
 func newValidatorExprEnv(typ string, value driver.Value) map[string]any {
-	return map[string]any{
-		"value": value,
-		"type":  typ,
-	}
+	e := exprFuncs(typ)
+	e["value"] = value
+	e["type"] = typ
+
+	return e
 }
@@ -1,9 +1,14 @@
 package tabsync
 
 import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/quenbyako/sqltest/dbenv"
 )
 
 func Test_cmpConst(t *testing.T) {
@@ -31,7 +36,7 @@ func Test_cmpConst(t *testing.T) {
 		b: map[string]Validator{
 			"id": mustValidator("text", "1"),
 		},
-		want: -1,
+		want: 1,
 	}, {
 		name:  "Different values",
 		pkeys: []string{"id"},
@@ -78,6 +83,20 @@ func Test_cmpConst(t *testing.T) {
 	}
 }
 
+func Test_cmpValue(t *testing.T) {
+	for _, tt := range []struct {
+		a, b driver.Value
+		want int
+	}{
+		{a: int64(2), b: 1.5, want: 1},
+		{a: "b", b: []byte("a"), want: 1},
+	} {
+		t.Run(fmt.Sprintf("%T %v vs %T %v", tt.a, tt.a, tt.b, tt.b), func(t *testing.T) {
+			require.Equal(t, tt.want, cmpValue(false)(tt.a, tt.b))
+		})
+	}
+}
+
 func mustValidator(typ, expr string) Validator {
 	v, err := newValidator(nil)("", typ, expr)
 	if err != nil {
@@ -85,3 +104,163 @@ func mustValidator(typ, expr string) Validator {
 	}
 	return v
 }
+
+func TestValidateTableNullable(t *testing.T) {
+	got := dbenv.TableData{
+		Schema: dbenv.TableSchema{
+			PrimaryKeys: []string{"id"},
+			Types: []dbenv.ColumnType{
+				{Name: "id", Typ: "integer"},
+				{Name: "name", Typ: "text", Nullable: true},
+				{Name: "email", Typ: "text"},
+			},
+		},
+		Rows: []dbenv.TableRow{
+			{"id": int64(1), "name": nil, "email": nil},
+		},
+	}
+
+	err := validateTable(got, []map[string]Validator{{
+		"id":    mustValidator("int", "1"),
+		"name":  mustValidator("text", "=value == nil"),
+		"email": mustValidator("text", "=value == nil"),
+	}}, false)
+	require.EqualError(t, err, `row map[string]driver.Value{"id":1}: key "email": not expected null value, got <nil>`)
+}
+
+func TestValidateMultiset(t *testing.T) {
+	got := dbenv.TableData{Rows: []dbenv.TableRow{
+		{"user_id": int64(1), "group_id": int64(1)},
+		{"user_id": int64(1), "group_id": int64(2)},
+		{"user_id": int64(2), "group_id": int64(2)},
+	}}
+
+	for _, tt := range []struct {
+		name    string
+		want    []map[string]Validator
+		strict  bool
+		wantErr string
+	}{{
+		name: "Any order",
+		want: []map[string]Validator{
+			{"user_id": mustValidator("int", "2"), "group_id": mustValidator("int", "2")},
+			{"user_id": mustValidator("int", "1"), "group_id": mustValidator("int", "1")},
+		},
+	}, {
+		// first expected row fits both rows of user 1, but must be paired
+		// with the second one, so the next row has a pair too.
+		name: "Expressions",
+		want: []map[string]Validator{
+			{"user_id": mustValidator("int", "1"), "group_id": mustValidator("int", "=value > 0")},
+			{"user_id": mustValidator("int", "1"), "group_id": mustValidator("int", "1")},
+			{"user_id": mustValidator("int", "=value > 1")},
+		},
+		strict: true,
+	}, {
+		name: "Duplicates",
+		want: []map[string]Validator{
+			{"user_id": mustValidator("int", "2")},
+			{"user_id": mustValidator("int", "2"), "group_id": mustValidator("int", "=value > 1")},
+		},
+		wantErr: "row 1: not found in database\n" +
+			`row 1: key "user_id": mismatched values: got 1, want 2`,
+	}, {
+		name: "Strict",
+		want: []map[string]Validator{
+			{"user_id": mustValidator("int", "1"), "group_id": mustValidator("int", "1")},
+			NotExists(map[string]Validator{"user_id": mustValidator("int", "3")}),
+		},
+		strict: true,
+		wantErr: `row map[string]driver.Value{"group_id":2, "user_id":1}: not expected` + "\n" +
+			`row map[string]driver.Value{"group_id":2, "user_id":2}: not expected`,
+	}, {
+		name: "Absent",
+		want: []map[string]Validator{
+			NotExists(map[string]Validator{"user_id": mustValidator("int", "2")}),
+		},
+		wantErr: `row 0: exists in database, but must not`,
+	}, {
+		name: "Absent with expressions",
+		want: []map[string]Validator{
+			NotExists(map[string]Validator{"user_id": mustValidator("int", "=value > 2")}),
+		},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTable(got, tt.want, tt.strict)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNotExists(t *testing.T) {
+	row := map[string]Validator{"id": mustValidator("int", "1")}
+
+	// row is marked without extra columns.
+	absent := NotExists(row)
+	require.Len(t, absent, 1)
+	require.True(t, isAbsent(absent))
+	require.False(t, isAbsent(row))
+
+	unwrapped, ok := withoutAbsent(absent)
+	require.True(t, ok)
+	require.IsType(t, constValidator{}, unwrapped["id"])
+}
+
+func TestValidationError(t *testing.T) {
+	err := validateTable(dbenv.TableData{
+		Schema: dbenv.TableSchema{PrimaryKeys: []string{"id"}},
+		Rows: []dbenv.TableRow{
+			{"id": int64(1), "name": "John", "score": int64(10)},
+			{"id": int64(2), "name": "Jane", "score": nil},
+		},
+	}, []map[string]Validator{
+		{"id": mustValidator("int", "1"), "name": mustValidator("text", "Johnny"), "score": mustValidator("int", "=value > 5")},
+		{"id": mustValidator("int", "3"), "name": mustValidator("text", "Bob")},
+	}, true)
+
+	require.EqualError(t, err, strings.Join([]string{
+		`row map[string]driver.Value{"id":1}: key "name": mismatched values: got "John", want "Johnny"`,
+		`row map[string]driver.Value{"id":3}: not found in database`,
+		`row map[string]driver.Value{"id":2}: not expected`,
+	}, "\n"))
+
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	require.Len(t, verr.Tables, 1)
+	rows := verr.Tables[0].Rows
+	require.Equal(t, []RowKind{RowDiffers, RowMissing, RowUnexpected}, []RowKind{rows[0].Kind, rows[1].Kind, rows[2].Kind})
+	require.Equal(t, "name", rows[0].Cells[0].Column)
+	require.Equal(t, "John", rows[0].Cells[0].Got)
+
+	require.Equal(t, strings.Join([]string{
+		`result:`,
+		`    id name      score      | id name    score`,
+		`  ~ 1  "Johnny"* =value > 5 | 1  "John"* 10`,
+		`  - 3  "Bob"                |`,
+		`  +                         | 2  "Jane"  NULL`,
+	}, "\n"), fmt.Sprintf("%+v", err))
+	require.Contains(t, verr.Diff(true), "\x1b[31m\"John\"\x1b[0m")
+}
+
+func TestValidateTableConvertedKeys(t *testing.T) {
+	got := dbenv.TableData{
+		Schema: dbenv.TableSchema{PrimaryKeys: []string{"price"}},
+		Rows: []dbenv.TableRow{
+			{"price": []byte("10.00"), "name": "large"},
+			{"price": []byte("1.50"), "name": "small"},
+		},
+	}
+
+	err := validateTable(got, []map[string]Validator{{
+		"price": mustValidator("numeric", "1.50"),
+		"name":  mustValidator("text", "small"),
+	}, {
+		"price": mustValidator("numeric", "10"),
+		"name":  mustValidator("text", "large"),
+	}}, true)
+	require.NoError(t, err)
+}